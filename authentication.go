@@ -1,7 +1,10 @@
 package emongo
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -9,6 +12,67 @@ import (
 type Authentication struct {
 	// TLS authentication
 	TLS *TLSConfig
+	// Credential 账号认证，设置后账号密码不需要写在DSN中
+	Credential *CredentialConfig
+}
+
+// CredentialConfig 账号认证配置
+type CredentialConfig struct {
+	// Mechanism 认证方式，支持SCRAM-SHA-1、SCRAM-SHA-256、MONGODB-X509、PLAIN、MONGODB-AWS，为空时由服务端协商
+	Mechanism string
+	// Username 用户名，MONGODB-X509为空时使用客户端证书的subject，MONGODB-AWS对应access key id
+	Username string
+	// Password 密码，MONGODB-AWS对应secret access key
+	Password string
+	// Source 认证数据库，为空时SCRAM默认为DSN中的数据库或者admin，X509、PLAIN、AWS默认为$external
+	Source string
+	// MechanismProperties 认证方式的额外参数，例如MONGODB-AWS的AWS_SESSION_TOKEN
+	MechanismProperties map[string]string
+	// UsernameFile、PasswordFile 从文件中读取用户名、密码，例如挂载的secret文件，优先于Username、Password
+	UsernameFile string
+	PasswordFile string
+	// UsernameEnv、PasswordEnv 从环境变量中读取用户名、密码，优先于Username、Password
+	UsernameEnv string
+	PasswordEnv string
+	// RotationInterval 定时重新读取账号密码，变化后使用新的账号密码重建连接池，为0时只在启动时读取
+	RotationInterval time.Duration
+}
+
+var authMechanisms = map[string]struct{}{
+	"SCRAM-SHA-1":   {},
+	"SCRAM-SHA-256": {},
+	"MONGODB-X509":  {},
+	"PLAIN":         {},
+	"MONGODB-AWS":   {},
+}
+
+// provider 根据配置返回CredentialProvider，没有配置文件或者环境变量时返回nil
+func (c *CredentialConfig) provider() CredentialProvider {
+	if c.UsernameFile != "" || c.PasswordFile != "" {
+		return NewFileCredentialProvider(c.UsernameFile, c.PasswordFile)
+	}
+	if c.UsernameEnv != "" || c.PasswordEnv != "" {
+		return NewEnvCredentialProvider(c.UsernameEnv, c.PasswordEnv)
+	}
+	return nil
+}
+
+// MarshalJSON 打印配置时隐藏密码
+func (c CredentialConfig) MarshalJSON() ([]byte, error) {
+	type credential CredentialConfig
+	masked := credential(c)
+	if masked.Password != "" {
+		masked.Password = "******"
+	}
+	if _, ok := masked.MechanismProperties["AWS_SESSION_TOKEN"]; ok {
+		props := make(map[string]string, len(masked.MechanismProperties))
+		for k, v := range masked.MechanismProperties {
+			props[k] = v
+		}
+		props["AWS_SESSION_TOKEN"] = "******"
+		masked.MechanismProperties = props
+	}
+	return json.Marshal(masked)
 }
 
 func (config *Authentication) ConfigureAuthentication(opts *options.ClientOptions) (err error) {
@@ -17,6 +81,11 @@ func (config *Authentication) ConfigureAuthentication(opts *options.ClientOption
 			return err
 		}
 	}
+	if config.Credential != nil {
+		if err = configureCredential(config.Credential, opts); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -25,9 +94,56 @@ func configureTLS(config *TLSConfig, opts *options.ClientOptions) error {
 	if err != nil {
 		return fmt.Errorf("error loading tls config: %w", err)
 	}
-	if tlsConfig != nil && tlsConfig.InsecureSkipVerify {
-		opts.TLSConfig = tlsConfig
+	if tlsConfig == nil {
 		return nil
 	}
+	opts.SetTLSConfig(tlsConfig)
+	if config.DisableOCSPEndpointCheck {
+		opts.SetDisableOCSPEndpointCheck(true)
+	}
+	return nil
+}
+
+// configureCredential 在DSN中的认证参数基础上覆盖配置中不为空的字段
+func configureCredential(config *CredentialConfig, opts *options.ClientOptions) error {
+	mechanism := strings.ToUpper(config.Mechanism)
+	if mechanism != "" {
+		if _, ok := authMechanisms[mechanism]; !ok {
+			return fmt.Errorf("unsupported auth mechanism: %q", config.Mechanism)
+		}
+	}
+
+	credential := options.Credential{}
+	if opts.Auth != nil {
+		credential = *opts.Auth
+	}
+	if mechanism != "" {
+		credential.AuthMechanism = mechanism
+	}
+	if config.Username != "" {
+		credential.Username = config.Username
+	}
+	if config.Password != "" {
+		credential.Password = config.Password
+		credential.PasswordSet = true
+	}
+	if config.Source != "" {
+		credential.AuthSource = config.Source
+	}
+	if len(config.MechanismProperties) > 0 {
+		credential.AuthMechanismProperties = config.MechanismProperties
+	}
+
+	switch credential.AuthMechanism {
+	case "MONGODB-X509":
+		if credential.PasswordSet {
+			return fmt.Errorf("password must not be set for auth mechanism %s", credential.AuthMechanism)
+		}
+	case "SCRAM-SHA-1", "SCRAM-SHA-256", "PLAIN":
+		if credential.Username == "" {
+			return fmt.Errorf("username is required for auth mechanism %s", credential.AuthMechanism)
+		}
+	}
+	opts.SetAuth(credential)
 	return nil
 }
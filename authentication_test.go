@@ -0,0 +1,35 @@
+package emongo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestAuthentication_Credential(t *testing.T) {
+	opts := options.Client().ApplyURI("mongodb://dsnuser@localhost:27017/test?authSource=admin")
+	auth := Authentication{Credential: &CredentialConfig{
+		Mechanism: "scram-sha-256",
+		Password:  "secret",
+	}}
+	assert.NoError(t, auth.ConfigureAuthentication(opts))
+	assert.Equal(t, "SCRAM-SHA-256", opts.Auth.AuthMechanism)
+	assert.Equal(t, "dsnuser", opts.Auth.Username)
+	assert.Equal(t, "secret", opts.Auth.Password)
+	assert.True(t, opts.Auth.PasswordSet)
+	assert.Equal(t, "admin", opts.Auth.AuthSource)
+
+	auth = Authentication{Credential: &CredentialConfig{Mechanism: "MONGODB-X509", Password: "secret"}}
+	assert.Error(t, auth.ConfigureAuthentication(options.Client()))
+
+	auth = Authentication{Credential: &CredentialConfig{Mechanism: "MONGODB-CR", Username: "user"}}
+	assert.Error(t, auth.ConfigureAuthentication(options.Client()))
+}
+
+func TestCredentialConfig_MarshalJSON(t *testing.T) {
+	res, err := json.Marshal(CredentialConfig{Username: "user", Password: "secret"})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(res), "secret")
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // The defaults should be a safe configuration
@@ -33,6 +34,19 @@ type TLSConfig struct {
 	// MaxVersion sets the maximum TLS version that is acceptable.
 	// If not set, refer to crypto/tls for defaults. (optional)
 	MaxVersion string
+	// ServerName overrides the server name used for SNI and certificate verification.
+	// If not set, the host of each server in the DSN is used. (optional)
+	ServerName string
+	// CipherSuites is a list of cipher suite names, e.g. "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256".
+	// It only applies to TLS 1.2 and below. If not set, refer to crypto/tls for defaults. (optional)
+	CipherSuites []string
+	// DisableOCSPEndpointCheck disables reaching out to OCSP responders when the server
+	// certificate has no stapled OCSP response. (optional)
+	DisableOCSPEndpointCheck bool
+	// ReloadInterval is the interval to check CAFile, CertFile and KeyFile for changes.
+	// New connections use the reloaded certificates, existing connections are not affected.
+	// When CAFile is reloaded, OCSP endpoint check is skipped. If not set, certificates are loaded once. (optional)
+	ReloadInterval time.Duration
 }
 
 func (c *TLSConfig) LoadTLSConfig() (*tls.Config, error) {
@@ -50,12 +64,13 @@ func (c *TLSConfig) LoadTLSConfig() (*tls.Config, error) {
 	if (c.CertFile == "" && c.KeyFile != "") || (c.CertFile != "" && c.KeyFile == "") {
 		return nil, errors.New("for auth via TLS, either both certificate and key must be supplied, or neither")
 	}
-	var cert tls.Certificate
+	var certificates []tls.Certificate
 	if c.CertFile != "" && c.KeyFile != "" {
-		cert, err = tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
 		if err != nil {
 			return nil, fmt.Errorf("could not load TLS client key/certificate from %s:%s: %s", c.KeyFile, c.CertFile, err)
 		}
+		certificates = append(certificates, cert)
 	}
 
 	minVersion, err := convertVersion(c.MinVersion, defaultMinTLSVersion)
@@ -66,12 +81,18 @@ func (c *TLSConfig) LoadTLSConfig() (*tls.Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid TLS max_version: %w", err)
 	}
+	cipherSuites, err := convertCipherSuites(c.CipherSuites)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS cipher_suites: %w", err)
+	}
 	return &tls.Config{
 		RootCAs:            certPool,
-		Certificates:       []tls.Certificate{cert},
+		Certificates:       certificates,
 		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec
 		MinVersion:         minVersion,
 		MaxVersion:         maxVersion,
+		ServerName:         c.ServerName,
+		CipherSuites:       cipherSuites,
 	}, nil
 }
 
@@ -98,6 +119,25 @@ func convertVersion(version string, defaultVersion uint16) (uint16, error) {
 	return val, nil
 }
 
+func convertCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	suites := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := suites[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported or insecure cipher suite: %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 var tlsProtocolVersions = map[string]uint16{
 	"1.0": tls.VersionTLS10,
 	"1.1": tls.VersionTLS11,
@@ -0,0 +1,173 @@
+package emongo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gotomicro/ego/core/elog"
+)
+
+// tlsReloader 定时检查CAFile、CertFile、KeyFile的修改时间，文件变化后重新加载证书
+// 已经建立的连接不受影响，新建的连接通过GetClientCertificate、VerifyConnection使用最新的证书
+type tlsReloader struct {
+	config *TLSConfig
+	name   string
+	logger *elog.Component
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	certPool *x509.CertPool
+	modTimes map[string]time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newTLSReloader(config *TLSConfig, name string, logger *elog.Component) (*tlsReloader, error) {
+	r := &tlsReloader{
+		config: config,
+		name:   name,
+		logger: logger,
+		stop:   make(chan struct{}),
+	}
+	r.modTimes = r.statFiles()
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// apply 替换tls.Config中静态的证书，driver为每个连接Clone配置时会保留这些回调
+func (r *tlsReloader) apply(tlsConfig *tls.Config) {
+	if r.config.CertFile != "" {
+		tlsConfig.Certificates = nil
+		tlsConfig.GetClientCertificate = r.getClientCertificate
+	}
+	// 没有配置CAFile时使用系统证书，不需要重新加载
+	if r.config.CAFile != "" && !r.config.InsecureSkipVerify {
+		// 由VerifyConnection使用最新的CA校验服务端证书，此时driver不会再进行OCSP检查
+		tlsConfig.RootCAs = nil
+		tlsConfig.InsecureSkipVerify = true //nolint:gosec
+		tlsConfig.VerifyConnection = r.verifyConnection
+	}
+}
+
+func (r *tlsReloader) load() error {
+	var certPool *x509.CertPool
+	var err error
+	if r.config.CAFile != "" {
+		certPool, err = r.config.loadCert(r.config.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to load CA CertPool: %w", err)
+		}
+	}
+	var cert *tls.Certificate
+	if r.config.CertFile != "" && r.config.KeyFile != "" {
+		keyPair, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+		if err != nil {
+			return fmt.Errorf("could not load TLS client key/certificate from %s:%s: %s", r.config.KeyFile, r.config.CertFile, err)
+		}
+		cert = &keyPair
+	}
+	r.mu.Lock()
+	r.certPool = certPool
+	r.cert = cert
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *tlsReloader) statFiles() map[string]time.Time {
+	modTimes := make(map[string]time.Time, 3)
+	for _, file := range []string{r.config.CAFile, r.config.CertFile, r.config.KeyFile} {
+		if file == "" {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil {
+			modTimes[file] = info.ModTime()
+		}
+	}
+	return modTimes
+}
+
+// check 有文件发生变化时重新加载，加载失败时继续使用旧的证书，直到文件再次变化
+func (r *tlsReloader) check() {
+	modTimes := r.statFiles()
+	changed := len(modTimes) != len(r.modTimes)
+	for file, modTime := range modTimes {
+		if !r.modTimes[file].Equal(modTime) {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	r.modTimes = modTimes
+
+	if err := r.load(); err != nil {
+		TLSReloadCounter.WithLabelValues(metricType, r.name, "fail").Inc()
+		r.logger.Error("mongo tls certificate reload fail", elog.FieldErr(err))
+		return
+	}
+	TLSReloadCounter.WithLabelValues(metricType, r.name, "success").Inc()
+	r.logger.Info("mongo tls certificate reloaded", elog.FieldValueAny(modTimes))
+}
+
+func (r *tlsReloader) start() {
+	go func() {
+		ticker := time.NewTicker(r.config.ReloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.check()
+			}
+		}
+	}()
+}
+
+func (r *tlsReloader) close() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+}
+
+func (r *tlsReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.cert == nil {
+		// 没有证书时返回空证书，由服务端决定是否拒绝
+		return &tls.Certificate{}, nil
+	}
+	return r.cert, nil
+}
+
+func (r *tlsReloader) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("mongo server did not provide a certificate")
+	}
+	r.mu.RLock()
+	certPool := r.certPool
+	r.mu.RUnlock()
+
+	serverName := cs.ServerName
+	if r.config.ServerName != "" {
+		serverName = r.config.ServerName
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         certPool,
+		Intermediates: intermediates,
+	})
+	return err
+}
@@ -0,0 +1,64 @@
+package emongo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSReloader(t *testing.T) {
+	dir := t.TempDir()
+	caFile, certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	ca := newTestCert(t, "ca", nil, true)
+	server := newTestCert(t, "server", ca, false, "mongo.internal")
+	client := newTestCert(t, "client", ca, false)
+	writeFile(t, caFile, ca.pem)
+	writeFile(t, certFile, client.pem)
+	writeFile(t, keyFile, client.kpem)
+
+	config := &TLSConfig{
+		Enabled:        true,
+		CAFile:         caFile,
+		CertFile:       certFile,
+		KeyFile:        keyFile,
+		ServerName:     "mongo.internal",
+		ReloadInterval: time.Second,
+	}
+	tlsConfig, err := config.LoadTLSConfig()
+	require.NoError(t, err)
+	reloader, err := newTLSReloader(config, "mongo", testLogger)
+	require.NoError(t, err)
+	reloader.apply(tlsConfig)
+	assert.NoError(t, handshake(t, ca, server, tlsConfig))
+
+	// 证书轮换，新的CA签发的服务端证书在重新加载前无法通过校验
+	newCA := newTestCert(t, "new-ca", nil, true)
+	newServer := newTestCert(t, "server", newCA, false, "mongo.internal")
+	newClient := newTestCert(t, "client", newCA, false)
+	assert.Error(t, handshake(t, newCA, newServer, tlsConfig))
+
+	// 只写了一半时加载失败，继续使用旧的证书
+	writeFile(t, certFile, newClient.pem)
+	touch(t, certFile)
+	reloader.check()
+	assert.NoError(t, handshake(t, ca, server, tlsConfig))
+
+	writeFile(t, caFile, newCA.pem)
+	writeFile(t, keyFile, newClient.kpem)
+	touch(t, caFile, keyFile)
+	reloader.check()
+	assert.NoError(t, handshake(t, newCA, newServer, tlsConfig))
+	assert.Error(t, handshake(t, ca, server, tlsConfig))
+}
+
+// touch 修改文件的修改时间，避免文件系统的时间精度导致检查不到变化
+func touch(t *testing.T, files ...string) {
+	mtime := time.Now().Add(time.Minute)
+	for _, file := range files {
+		require.NoError(t, os.Chtimes(file, mtime, mtime))
+	}
+}
@@ -0,0 +1,165 @@
+package emongo
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+	kpem []byte
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert, isCA bool, dnsNames ...string) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	tpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		DNSNames:              dnsNames,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	signer, signerKey := tpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return &testCert{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		kpem: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	}
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.pem, c.kpem)
+	require.NoError(t, err)
+	return cert
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	require.NoError(t, os.WriteFile(path, data, 0600))
+}
+
+// handshake 在本地启动一个要求客户端证书的TLS服务端，模拟mongo服务端
+func handshake(t *testing.T, ca, server *testCert, client *tls.Config) error {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	errCh := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		srv := tls.Server(conn, &tls.Config{
+			Certificates: []tls.Certificate{server.tlsCertificate(t)},
+			ClientCAs:    pool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		})
+		defer srv.Close()
+		err = srv.Handshake()
+		if err == nil {
+			_, err = srv.Write([]byte{1})
+		}
+		errCh <- err
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	cli := tls.Client(conn, client)
+	defer cli.Close()
+	// TLS1.3中服务端校验客户端证书失败时，客户端握手依然成功，需要读取数据才能拿到错误
+	if err = cli.Handshake(); err == nil {
+		_, err = cli.Read(make([]byte, 1))
+	}
+	if srvErr := <-errCh; srvErr != nil {
+		return srvErr
+	}
+	return err
+}
+
+func TestTLSConfig_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil, true)
+	server := newTestCert(t, "server", ca, false, "mongo.internal")
+	client := newTestCert(t, "client", ca, false)
+	writeFile(t, filepath.Join(dir, "ca.pem"), ca.pem)
+	writeFile(t, filepath.Join(dir, "client.pem"), client.pem)
+	writeFile(t, filepath.Join(dir, "client.key"), client.kpem)
+
+	config := &TLSConfig{
+		Enabled:                  true,
+		CAFile:                   filepath.Join(dir, "ca.pem"),
+		CertFile:                 filepath.Join(dir, "client.pem"),
+		KeyFile:                  filepath.Join(dir, "client.key"),
+		ServerName:               "mongo.internal",
+		CipherSuites:             []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+		DisableOCSPEndpointCheck: true,
+	}
+	opts := options.Client()
+	require.NoError(t, configureTLS(config, opts))
+	require.NotNil(t, opts.TLSConfig)
+	assert.False(t, opts.TLSConfig.InsecureSkipVerify)
+	assert.Equal(t, "mongo.internal", opts.TLSConfig.ServerName)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, opts.TLSConfig.CipherSuites)
+	assert.True(t, *opts.DisableOCSPEndpointCheck)
+	assert.NoError(t, handshake(t, ca, server, opts.TLSConfig))
+
+	// SNI与服务端证书不匹配
+	config.ServerName = "other.internal"
+	tlsConfig, err := config.LoadTLSConfig()
+	require.NoError(t, err)
+	assert.Error(t, handshake(t, ca, server, tlsConfig))
+
+	// 不提供客户端证书
+	config.ServerName = "mongo.internal"
+	config.CertFile, config.KeyFile = "", ""
+	tlsConfig, err = config.LoadTLSConfig()
+	require.NoError(t, err)
+	assert.Empty(t, tlsConfig.Certificates)
+	assert.Error(t, handshake(t, ca, server, tlsConfig))
+}
+
+func TestTLSConfig_Invalid(t *testing.T) {
+	_, err := (&TLSConfig{Enabled: true, CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}).LoadTLSConfig()
+	assert.Error(t, err)
+	_, err = (&TLSConfig{Enabled: true, CertFile: "client.pem"}).LoadTLSConfig()
+	assert.Error(t, err)
+	_, err = (&TLSConfig{Enabled: true, MinVersion: "1.4"}).LoadTLSConfig()
+	assert.Error(t, err)
+}
@@ -0,0 +1,178 @@
+package emongo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"go.mongodb.org/mongo-driver/tag"
+)
+
+// minMaxStaleness 服务端要求maxStaleness不小于90s
+const minMaxStaleness = 90 * time.Second
+
+// minHeartbeatInterval driver允许的最小心跳间隔
+const minHeartbeatInterval = 500 * time.Millisecond
+
+// ReadPreferenceConfig 读偏好配置
+type ReadPreferenceConfig struct {
+	Mode         string              `json:"mode" toml:"mode"`                 // Mode 读偏好模式，支持primary、primaryPreferred、secondary、secondaryPreferred、nearest
+	TagSets      []map[string]string `json:"tagSets" toml:"tagSets"`           // TagSets 按顺序匹配节点标签，例如[{dc="sh"},{}]，primary模式不能设置
+	MaxStaleness time.Duration       `json:"maxStaleness" toml:"maxStaleness"` // MaxStaleness secondary允许的最大复制延迟，最小90s，primary模式不能设置
+}
+
+// WriteConcernConfig 写关注配置
+type WriteConcernConfig struct {
+	W        string        `json:"w" toml:"w"`               // W 需要确认写入的节点数量，支持数字、majority或者自定义的tag set名称
+	J        bool          `json:"j" toml:"j"`               // J 是否需要等待写入journal
+	WTimeout time.Duration `json:"wTimeout" toml:"wTimeout"` // WTimeout 等待写关注的超时时间
+}
+
+var compressors = map[string]struct{}{
+	"zstd":   {},
+	"snappy": {},
+	"zlib":   {},
+}
+
+var readConcernLevels = map[string]struct{}{
+	"local":        {},
+	"available":    {},
+	"majority":     {},
+	"linearizable": {},
+	"snapshot":     {},
+}
+
+// configureClientOptions 在DSN之后设置配置中不为空的driver参数，并校验参数是否合法
+func (config *config) configureClientOptions(opts *options.ClientOptions) error {
+	if config.AppName != "" {
+		opts.SetAppName(config.AppName)
+	}
+	if len(config.Compressors) > 0 {
+		for _, compressor := range config.Compressors {
+			if _, ok := compressors[compressor]; !ok {
+				return fmt.Errorf("unsupported compressor: %q", compressor)
+			}
+		}
+		opts.SetCompressors(config.Compressors)
+	}
+	rp, err := config.ReadPreference.readPref()
+	if err != nil {
+		return err
+	}
+	if rp != nil {
+		opts.SetReadPreference(rp)
+	}
+	if config.ReadConcern != "" {
+		if _, ok := readConcernLevels[config.ReadConcern]; !ok {
+			return fmt.Errorf("unsupported read concern: %q", config.ReadConcern)
+		}
+		opts.SetReadConcern(readconcern.New(readconcern.Level(config.ReadConcern)))
+	}
+	wc, err := config.WriteConcern.writeConcern()
+	if err != nil {
+		return err
+	}
+	if wc != nil {
+		opts.SetWriteConcern(wc)
+	}
+	if config.RetryReads != nil {
+		opts.SetRetryReads(*config.RetryReads)
+	}
+	if config.RetryWrites != nil {
+		opts.SetRetryWrites(*config.RetryWrites)
+	}
+	if config.ServerSelectionTimeout > 0 {
+		opts.SetServerSelectionTimeout(config.ServerSelectionTimeout)
+	}
+	if config.HeartbeatInterval > 0 {
+		if config.HeartbeatInterval < minHeartbeatInterval {
+			return fmt.Errorf("heartbeatInterval must be at least %v", minHeartbeatInterval)
+		}
+		opts.SetHeartbeatInterval(config.HeartbeatInterval)
+	}
+	if config.LocalThreshold > 0 {
+		opts.SetLocalThreshold(config.LocalThreshold)
+	}
+	if config.ReplicaSet != "" {
+		opts.SetReplicaSet(config.ReplicaSet)
+	}
+	if config.Direct {
+		opts.SetDirect(true)
+	}
+	if config.LoadBalanced {
+		opts.SetLoadBalanced(true)
+	}
+	// 校验DSN以及参数之间的冲突，例如direct连接多个节点、loadBalanced与replicaSet同时使用
+	return opts.Validate()
+}
+
+func (c ReadPreferenceConfig) readPref() (*readpref.ReadPref, error) {
+	if c.Mode == "" {
+		if len(c.TagSets) > 0 || c.MaxStaleness > 0 {
+			return nil, fmt.Errorf("readPreference mode is required when tagSets or maxStaleness is set")
+		}
+		return nil, nil
+	}
+	mode, err := readpref.ModeFromString(c.Mode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid readPreference mode: %w", err)
+	}
+	var rpOpts []readpref.Option
+	if len(c.TagSets) > 0 {
+		tagSets := make([]tag.Set, 0, len(c.TagSets))
+		for _, tags := range c.TagSets {
+			tagSets = append(tagSets, tag.NewTagSetFromMap(tags))
+		}
+		rpOpts = append(rpOpts, readpref.WithTagSets(tagSets...))
+	}
+	if c.MaxStaleness > 0 {
+		if c.MaxStaleness < minMaxStaleness {
+			return nil, fmt.Errorf("readPreference maxStaleness must be at least %v", minMaxStaleness)
+		}
+		rpOpts = append(rpOpts, readpref.WithMaxStaleness(c.MaxStaleness))
+	}
+	// primary模式设置了tagSets或者maxStaleness时返回错误
+	rp, err := readpref.New(mode, rpOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid readPreference: %w", err)
+	}
+	return rp, nil
+}
+
+func (c WriteConcernConfig) writeConcern() (*writeconcern.WriteConcern, error) {
+	if c.W == "" && !c.J && c.WTimeout == 0 {
+		return nil, nil
+	}
+	var wcOpts []writeconcern.Option
+	switch w := strings.TrimSpace(c.W); {
+	case w == "":
+	case w == "majority":
+		wcOpts = append(wcOpts, writeconcern.WMajority())
+	default:
+		if n, err := strconv.Atoi(w); err == nil {
+			if n < 0 {
+				return nil, fmt.Errorf("invalid writeConcern w: %q", c.W)
+			}
+			wcOpts = append(wcOpts, writeconcern.W(n))
+		} else {
+			wcOpts = append(wcOpts, writeconcern.WTagSet(w))
+		}
+	}
+	if c.J {
+		wcOpts = append(wcOpts, writeconcern.J(true))
+	}
+	if c.WTimeout > 0 {
+		wcOpts = append(wcOpts, writeconcern.WTimeout(c.WTimeout))
+	}
+	wc := writeconcern.New(wcOpts...)
+	// w=0时不能要求journal
+	if !wc.IsValid() {
+		return nil, fmt.Errorf("invalid writeConcern: w=%q j=%v", c.W, c.J)
+	}
+	return wc, nil
+}
@@ -0,0 +1,92 @@
+package emongo
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func TestConfig_ClientOptions(t *testing.T) {
+	conf := econf.New()
+	require.NoError(t, conf.LoadFromReader(strings.NewReader(`
+[mongo]
+	dsn="mongodb://localhost:27017/test?appName=dsn&retryWrites=true"
+	dialTimeout="3s"
+	minPoolSize=5
+	slowLogThreshold="1s"
+	appName="emongo"
+	compressors=["zstd","snappy"]
+	readConcern="majority"
+	retryWrites=false
+	serverSelectionTimeout="5s"
+	heartbeatInterval="2s"
+	localThreshold="20ms"
+	replicaSet="rs0"
+	[mongo.readPreference]
+		mode="secondaryPreferred"
+		tagSets=[{dc="sh"},{}]
+		maxStaleness="120s"
+	[mongo.writeConcern]
+		w="majority"
+		j=true
+		wTimeout="1s"
+`), toml.Unmarshal))
+	config := DefaultConfig()
+	require.NoError(t, conf.UnmarshalKey("mongo", config))
+	assert.Equal(t, 3*time.Second, config.DialTimeout)
+	assert.Equal(t, 5, config.MinPoolSize)
+	assert.Equal(t, time.Second, config.SlowLogThreshold)
+
+	opts := options.Client().ApplyURI(config.DSN)
+	require.NoError(t, config.configureClientOptions(opts))
+	assert.Equal(t, "emongo", *opts.AppName)
+	assert.Equal(t, []string{"zstd", "snappy"}, opts.Compressors)
+	assert.Equal(t, readpref.SecondaryPreferredMode, opts.ReadPreference.Mode())
+	assert.Len(t, opts.ReadPreference.TagSets(), 2)
+	maxStaleness, _ := opts.ReadPreference.MaxStaleness()
+	assert.Equal(t, 120*time.Second, maxStaleness)
+	assert.Equal(t, "majority", opts.ReadConcern.GetLevel())
+	assert.Equal(t, "majority", opts.WriteConcern.GetW())
+	assert.True(t, opts.WriteConcern.GetJ())
+	assert.Equal(t, time.Second, opts.WriteConcern.GetWTimeout())
+	assert.False(t, *opts.RetryWrites)
+	assert.Nil(t, opts.RetryReads)
+	assert.Equal(t, 5*time.Second, *opts.ServerSelectionTimeout)
+	assert.Equal(t, 2*time.Second, *opts.HeartbeatInterval)
+	assert.Equal(t, 20*time.Millisecond, *opts.LocalThreshold)
+	assert.Equal(t, "rs0", *opts.ReplicaSet)
+}
+
+func TestConfig_ClientOptionsInvalid(t *testing.T) {
+	cases := map[string]func(c *config){
+		"compressor":      func(c *config) { c.Compressors = []string{"gzip"} },
+		"read preference": func(c *config) { c.ReadPreference.Mode = "secondaryOnly" },
+		"primary with tags": func(c *config) {
+			c.ReadPreference = ReadPreferenceConfig{Mode: "primary", TagSets: []map[string]string{{"dc": "sh"}}}
+		},
+		"tags without mode":  func(c *config) { c.ReadPreference.TagSets = []map[string]string{{"dc": "sh"}} },
+		"max staleness":      func(c *config) { c.ReadPreference = ReadPreferenceConfig{Mode: "secondary", MaxStaleness: time.Second} },
+		"read concern":       func(c *config) { c.ReadConcern = "strong" },
+		"unacknowledged j":   func(c *config) { c.WriteConcern = WriteConcernConfig{W: "0", J: true} },
+		"negative w":         func(c *config) { c.WriteConcern.W = "-1" },
+		"heartbeat interval": func(c *config) { c.HeartbeatInterval = 100 * time.Millisecond },
+		"direct multi hosts": func(c *config) { c.DSN = "mongodb://a:27017,b:27017"; c.Direct = true },
+		"load balanced rs":   func(c *config) { c.LoadBalanced = true; c.ReplicaSet = "rs0" },
+		"invalid dsn":        func(c *config) { c.DSN = "mysql://localhost" },
+	}
+	for name, fn := range cases {
+		t.Run(name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.DSN = "mongodb://localhost:27017/test"
+			fn(config)
+			assert.Error(t, config.configureClientOptions(options.Client().ApplyURI(config.DSN)))
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package emongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type processor func(c *Cmd, fn ProcessFn) error
+
+// ProcessFn 执行一次mongo操作，拦截器通过包装ProcessFn实现
+type ProcessFn func(*Cmd) error
+
+// Cmd 描述一次经过拦截器链的mongo操作
+// 拦截器可以在执行前读取或替换其中的字段，例如通过Ctx传递trace、租户信息或者设置超时
+type Cmd struct {
+	Ctx       context.Context // Ctx 调用方传入的context，wrapped方法会使用执行时的Ctx调用driver
+	Name      string          // Name 操作名称，例如Find、InsertOne、Ping
+	DbName    string          // DbName 数据库名称，Client级别的操作为空
+	CollName  string          // CollName 集合名称，非Collection级别的操作为空
+	Filter    interface{}     // Filter 查询条件，或者Aggregate、Watch的pipeline
+	Update    interface{}     // Update 更新内容或者替换的文档
+	Opts      interface{}     // Opts 调用方传入的options，例如[]*options.FindOptions
+	StartTime time.Time       // StartTime 操作开始的时间
+	Req       []interface{}   // Req 请求参数，只有开启logMode时才会记录
+	Res       interface{}     // Res 响应结果，只有开启logMode时才会记录
+
+	MatchedCount  int64 // MatchedCount 更新操作匹配的文档数量
+	ModifiedCount int64 // ModifiedCount 更新操作修改的文档数量
+	UpsertedCount int64 // UpsertedCount 更新操作upsert的文档数量
+	DeletedCount  int64 // DeletedCount 删除的文档数量
+	InsertedCount int64 // InsertedCount 写入的文档数量
+	ReturnedCount int64 // ReturnedCount 游标返回的文档数量，只有游标的All、Close操作会设置
+
+	IterationCost time.Duration // IterationCost 游标从创建到遍历结束的总耗时，只有游标的All、Close操作会设置
+
+	InTransaction bool // InTransaction 是否在事务中执行，事务中的请求失败后由事务整体重试
+	Attempt       int  // Attempt Client.Transaction的第几次尝试，从1开始，其他操作为0
+}
+
+func newCmd(ctx context.Context, name string) *Cmd {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &Cmd{
+		Ctx:           ctx,
+		Name:          name,
+		Req:           make([]interface{}, 0, 1),
+		StartTime:     time.Now(),
+		InTransaction: InTransaction(ctx),
+	}
+}
+
+func defaultProcessor(c *Cmd, fn ProcessFn) error {
+	return fn(c)
+}
+
+func logCmd(logMode bool, c *Cmd, res interface{}, req ...interface{}) {
+	c.setResultCount(res)
+	// 只有开启log模式才会记录req、res
+	if logMode {
+		c.Req = append(c.Req, req...)
+		switch res := res.(type) {
+		case *mongo.SingleResult:
+			val, _ := res.DecodeBytes()
+			c.Res = val
+		default:
+			c.Res = res
+		}
+	}
+}
+
+func (c *Cmd) setResultCount(res interface{}) {
+	switch res := res.(type) {
+	case *mongo.InsertOneResult:
+		if res != nil {
+			c.InsertedCount = 1
+		}
+	case *mongo.InsertManyResult:
+		if res != nil {
+			c.InsertedCount = int64(len(res.InsertedIDs))
+		}
+	case *mongo.UpdateResult:
+		if res != nil {
+			c.MatchedCount = res.MatchedCount
+			c.ModifiedCount = res.ModifiedCount
+			c.UpsertedCount = res.UpsertedCount
+		}
+	case *mongo.DeleteResult:
+		if res != nil {
+			c.DeletedCount = res.DeletedCount
+		}
+	case *mongo.BulkWriteResult:
+		if res != nil {
+			c.InsertedCount = res.InsertedCount
+			c.MatchedCount = res.MatchedCount
+			c.ModifiedCount = res.ModifiedCount
+			c.UpsertedCount = res.UpsertedCount
+			c.DeletedCount = res.DeletedCount
+		}
+	}
+}
@@ -1,18 +1,37 @@
 package emongo
 
 import (
+	"context"
+	"sync"
+	"sync/atomic"
+
 	"github.com/gotomicro/ego/core/elog"
+	"github.com/gotomicro/ego/core/standard"
 )
 
 const PackageName = "component.emongo"
 
 // Component client (cmdable and config)
 type Component struct {
-	config *config
+	name   string
+	conf   atomic.Pointer[config]
 	client *Client
 	logger *elog.Component
+	// unwatch 停止监听配置变化，没有名称的Component为nil
+	unwatch func()
+
+	tlsReloader       *tlsReloader
+	credentialRotator *credentialRotator
+	reconnector       *reconnector
+
+	healthMu   sync.RWMutex
+	health     Health
+	healthStop chan struct{}
+	healthOnce sync.Once
 }
 
+var _ standard.Component = (*Component)(nil)
+
 // Client returns emongo Client
 func (c *Component) Client() *Client {
 	return c.client
@@ -20,5 +39,70 @@ func (c *Component) Client() *Client {
 
 // DbName returns emongo Client
 func (c *Component) DbName() string {
-	return c.config.dbName
+	return c.config().dbName
+}
+
+// config 当前生效的配置，热更新后会替换
+func (c *Component) config() *config {
+	return c.conf.Load()
+}
+
+// Name 配置名称
+func (c *Component) Name() string {
+	return c.name
+}
+
+// PackageName 包名
+func (c *Component) PackageName() string {
+	return PackageName
+}
+
+// Init 初始化，连接在Build时已经建立
+func (c *Component) Init() error {
+	return nil
+}
+
+// Start 启动，连接在Build时已经建立
+func (c *Component) Start() error {
+	return nil
+}
+
+// Stop 停止，拒绝新的请求，最多等待ShutdownTimeout让进行中的请求结束后断开连接，超时或者ShutdownTimeout为0时强制断开连接
+// ego不会自动调用Component的Stop、GracefulStop，需要通过ego.WithAfterStopClean在server停止之后调用
+func (c *Component) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config().ShutdownTimeout)
+	defer cancel()
+	return c.Close(ctx)
+}
+
+// GracefulStop 优雅停止，拒绝新的请求，最多等待ShutdownTimeout让进行中的请求结束后断开连接
+func (c *Component) GracefulStop(ctx context.Context) error {
+	if c.config().ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config().ShutdownTimeout)
+		defer cancel()
+	}
+	return c.Close(ctx)
+}
+
+// Close 拒绝新的请求，等待进行中的请求结束或者ctx结束后断开连接，并从Component注册表中移除
+func (c *Component) Close(ctx context.Context) error {
+	instances.CompareAndDelete(c.name, c)
+	c.stopHealthProbe()
+	if c.tlsReloader != nil {
+		c.tlsReloader.close()
+	}
+	if c.credentialRotator != nil {
+		c.credentialRotator.close()
+	}
+	if c.reconnector != nil {
+		c.reconnector.close()
+	}
+	if c.unwatch != nil {
+		c.unwatch()
+	}
+	if c.client == nil {
+		return nil
+	}
+	return c.client.shutdown(ctx)
 }
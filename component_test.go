@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
@@ -54,8 +55,85 @@ func TestSession(t *testing.T) {
 		_ = coll.Drop(ctx)
 	}()
 	_, err = sess.WithTransaction(context.Background(), func(sessCtx SessionContext) (interface{}, error) {
-		res := coll.FindOne(sessCtx, bson.D{{"x", 1}})
+		res := coll.FindOne(sessCtx, bson.D{{Key: "x", Value: 1}})
 		return res, err
 	})
 	assert.NotNil(t, err, "expected WithTransaction error, got nil")
 }
+
+func TestTransaction(t *testing.T) {
+	var ctx = context.TODO()
+	client := DefaultContainer().Build(WithDSN(os.Getenv("EMONGO_DSN"))).Client()
+	coll := client.Database("foo").Collection("bar")
+	defer func() {
+		_ = coll.Drop(ctx)
+	}()
+	err := client.Transaction(ctx, func(sessCtx SessionContext) error {
+		assert.True(t, InTransaction(sessCtx))
+		_, err := coll.InsertOne(sessCtx, bson.D{{Key: "x", Value: 1}})
+		return err
+	})
+	assert.NoError(t, err)
+	n, err := coll.CountDocuments(ctx, bson.D{{Key: "x", Value: 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+// newStopComponent 创建Component，CountDocuments在release关闭前不会返回
+func newStopComponent(t *testing.T, shutdownTimeout time.Duration) (*Component, *mockDeployment, chan struct{}) {
+	comp, md := newHealthComponent(t, "mongo.stop")
+	conf := DefaultConfig()
+	conf.ShutdownTimeout = shutdownTimeout
+	comp.conf.Store(conf)
+	started, release := make(chan struct{}), make(chan struct{})
+	comp.client.wrapProcessor(InterceptorChain(func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
+			if cmd.Name == "CountDocuments" {
+				close(started)
+				<-release
+			}
+			return oldProcess(cmd)
+		}
+	}))
+	go func() {
+		_, _ = comp.Client().Database("test").Collection("cells").CountDocuments(context.Background(), bson.M{})
+	}()
+	<-started
+	return comp, md, release
+}
+
+func TestComponent_Stop(t *testing.T) {
+	comp, md, release := newStopComponent(t, time.Minute)
+	stopped := make(chan error, 1)
+	go func() { stopped <- comp.Stop() }()
+
+	// Stop等待进行中的请求结束后再断开连接
+	assert.Never(t, func() bool { return len(stopped) > 0 }, 200*time.Millisecond, 10*time.Millisecond)
+	assert.False(t, md.isDisconnected())
+	close(release)
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return after in-flight request finished")
+	}
+	assert.True(t, md.isDisconnected())
+}
+
+func TestComponent_StopWithoutShutdownTimeout(t *testing.T) {
+	comp, md, release := newStopComponent(t, 0)
+	defer close(release)
+
+	// ShutdownTimeout为0时不等待进行中的请求，立即强制断开连接
+	stopped := make(chan struct{})
+	go func() {
+		_ = comp.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop waited for in-flight request")
+	}
+	assert.True(t, md.isDisconnected())
+}
@@ -7,24 +7,74 @@ import (
 )
 
 type config struct {
-	DSN                        string        `json:"dsn" toml:"dsn"`     // DSN DSN地址
-	Debug                      bool          `json:"debug" toml:"debug"` // Debug 是否开启debug模式
-	DialTimeout                time.Duration // 连接超时
-	SocketTimeout              time.Duration `json:"socketTimeout" toml:"socketTimeout"` // SocketTimeout 创建连接的超时时间
-	MaxConnIdleTime            time.Duration `json:"maxConnIdleTime"`
-	MinPoolSize                int           // MinPoolSize 连接池大小(最小连接数)
-	MaxPoolSize                int           `json:"maxPoolSize" toml:"maxPoolSize"`                               // MaxPoolSize 连接池大小(最大连接数)
-	EnableMetricInterceptor    bool          `json:"enableMetricInterceptor" toml:"enableMetricInterceptor"`       // EnableMetricInterceptor 是否启用prometheus metric拦截器
-	EnableAccessInterceptorReq bool          `json:"enableAccessInterceptorReq" toml:"enableAccessInterceptorReq"` // EnableAccessInterceptorReq 是否启用access req拦截器，此配置只有在EnableAccessInterceptor=true时才会生效
-	EnableAccessInterceptorRes bool          `json:"enableAccessInterceptorRes" toml:"enableAccessInterceptorRes"` // EnableAccessInterceptorRes 是否启用access res拦截器，此配置只有在EnableAccessInterceptor=true时才会生效
-	EnableAccessInterceptor    bool          `json:"enableAccessInterceptor" toml:"enableAccessInterceptor"`       // EnableAccessInterceptor 是否启用access拦截器
-	EnableTraceInterceptor     bool          `json:"enableTraceInterceptor" toml:"enableTraceInterceptor"`         // EnableTraceInterceptor 是否启用trace拦截器
-	SlowLogThreshold           time.Duration // SlowLogThreshold 慢日志门限值，超过该门限值的请求，将被记录到慢日志中
-	OnFail                     string        // 创建连接的错误级别，=panic时，如果创建失败，立即panic，默认连接不上panic
-	Authentication             Authentication
-	interceptors               []Interceptor
-	keyName                    string
-	dbName                     string
+	DSN                        string                   `json:"dsn" toml:"dsn"`                                               // DSN DSN地址
+	Debug                      bool                     `json:"debug" toml:"debug"`                                           // Debug 是否开启debug模式
+	DialTimeout                time.Duration            `json:"dialTimeout" toml:"dialTimeout"`                               // DialTimeout 连接超时，为0时使用默认的10s
+	SocketTimeout              time.Duration            `json:"socketTimeout" toml:"socketTimeout"`                           // SocketTimeout 创建连接的超时时间
+	MaxConnIdleTime            time.Duration            `json:"maxConnIdleTime" toml:"maxConnIdleTime"`                       // MaxConnIdleTime 连接最大空闲时间
+	MinPoolSize                int                      `json:"minPoolSize" toml:"minPoolSize"`                               // MinPoolSize 连接池大小(最小连接数)
+	MaxPoolSize                int                      `json:"maxPoolSize" toml:"maxPoolSize"`                               // MaxPoolSize 连接池大小(最大连接数)
+	EnableMetricInterceptor    bool                     `json:"enableMetricInterceptor" toml:"enableMetricInterceptor"`       // EnableMetricInterceptor 是否启用prometheus metric拦截器
+	EnableAccessInterceptorReq bool                     `json:"enableAccessInterceptorReq" toml:"enableAccessInterceptorReq"` // EnableAccessInterceptorReq 是否启用access req拦截器，此配置只有在EnableAccessInterceptor=true时才会生效
+	EnableAccessInterceptorRes bool                     `json:"enableAccessInterceptorRes" toml:"enableAccessInterceptorRes"` // EnableAccessInterceptorRes 是否启用access res拦截器，此配置只有在EnableAccessInterceptor=true时才会生效
+	EnableAccessInterceptor    bool                     `json:"enableAccessInterceptor" toml:"enableAccessInterceptor"`       // EnableAccessInterceptor 是否启用access拦截器
+	EnableTraceInterceptor     bool                     `json:"enableTraceInterceptor" toml:"enableTraceInterceptor"`         // EnableTraceInterceptor 是否启用trace拦截器
+	SlowLogThreshold           time.Duration            `json:"slowLogThreshold" toml:"slowLogThreshold"`                     // SlowLogThreshold 慢日志门限值，超过该门限值的请求，将被记录到慢日志中
+	OnFail                     string                   `json:"onFail" toml:"onFail"`                                         // OnFail 创建连接的错误级别，=panic时，如果创建失败，立即panic；=lazy时，Build总是返回可用的Component，在后台重连，连接成功前请求返回ErrNotConnected
+	ShutdownTimeout            time.Duration            `json:"shutdownTimeout" toml:"shutdownTimeout"`                       // ShutdownTimeout 优雅停止时等待进行中请求结束的最长时间，超时后强制断开连接
+	EnablePoolMonitor          bool                     `json:"enablePoolMonitor" toml:"enablePoolMonitor"`                   // EnablePoolMonitor 是否开启连接池监控
+	EnableServerMonitor        bool                     `json:"enableServerMonitor" toml:"enableServerMonitor"`               // EnableServerMonitor 是否开启节点、拓扑变化以及心跳的监控和日志
+	EnableHealthProbe          bool                     `json:"enableHealthProbe" toml:"enableHealthProbe"`                   // EnableHealthProbe 是否开启后台健康检查
+	HealthProbeInterval        time.Duration            `json:"healthProbeInterval" toml:"healthProbeInterval"`               // HealthProbeInterval 后台健康检查的间隔
+	HealthProbeTimeout         time.Duration            `json:"healthProbeTimeout" toml:"healthProbeTimeout"`                 // HealthProbeTimeout 健康检查中每次探测的超时时间
+	EnableRetryInterceptor     bool                     `json:"enableRetryInterceptor" toml:"enableRetryInterceptor"`         // EnableRetryInterceptor 是否启用重试拦截器，读操作遇到网络错误、主从切换，写操作遇到节点不是primary、选择节点超时时重试
+	RetryMaxAttempts           int                      `json:"retryMaxAttempts" toml:"retryMaxAttempts"`                     // RetryMaxAttempts 重试拦截器最多尝试的次数，包括第一次
+	RetryMinBackoff            time.Duration            `json:"retryMinBackoff" toml:"retryMinBackoff"`                       // RetryMinBackoff 第一次重试前等待的时间，之后每次翻倍，并加入随机抖动
+	RetryMaxBackoff            time.Duration            `json:"retryMaxBackoff" toml:"retryMaxBackoff"`                       // RetryMaxBackoff 重试前最长等待的时间
+	EnableBreakerInterceptor   bool                     `json:"enableBreakerInterceptor" toml:"enableBreakerInterceptor"`     // EnableBreakerInterceptor 是否启用熔断拦截器，熔断期间请求直接返回ErrCircuitOpen
+	BreakerPerCollection       bool                     `json:"breakerPerCollection" toml:"breakerPerCollection"`             // BreakerPerCollection 是否按集合分别熔断，默认整个Component使用一个熔断器
+	BreakerWindow              time.Duration            `json:"breakerWindow" toml:"breakerWindow"`                           // BreakerWindow 统计失败率的时间窗口
+	BreakerMinRequests         int                      `json:"breakerMinRequests" toml:"breakerMinRequests"`                 // BreakerMinRequests 时间窗口内请求数达到该值后才会熔断
+	BreakerErrorRate           float64                  `json:"breakerErrorRate" toml:"breakerErrorRate"`                     // BreakerErrorRate 失败率达到该值时熔断，网络错误、超时、主从切换以及慢请求算作失败
+	BreakerSlowThreshold       time.Duration            `json:"breakerSlowThreshold" toml:"breakerSlowThreshold"`             // BreakerSlowThreshold 超过该耗时的请求算作失败，为0时不统计慢请求
+	BreakerOpenTimeout         time.Duration            `json:"breakerOpenTimeout" toml:"breakerOpenTimeout"`                 // BreakerOpenTimeout 熔断多久之后放行探测请求
+	BreakerHalfOpenProbes      int                      `json:"breakerHalfOpenProbes" toml:"breakerHalfOpenProbes"`           // BreakerHalfOpenProbes 半开状态下放行的探测请求数，全部成功后恢复，任意一个失败重新熔断
+	EnableLimitInterceptor     bool                     `json:"enableLimitInterceptor" toml:"enableLimitInterceptor"`         // EnableLimitInterceptor 是否启用限流拦截器
+	Limits                     []LimitRule              `json:"limits" toml:"limits"`                                         // Limits 限流规则，可以按Component、集合、操作名称分别限制请求速率和并发数
+	DefaultTimeout             time.Duration            `json:"defaultTimeout" toml:"defaultTimeout"`                         // DefaultTimeout 调用方的ctx没有设置超时时间时，读写操作默认的超时时间，为0时不设置
+	OperationTimeouts          map[string]time.Duration `json:"operationTimeouts" toml:"operationTimeouts"`                   // OperationTimeouts 按操作设置默认的超时时间，key为操作名称或者read、write，优先级高于DefaultTimeout
+	TimeoutMaxTimeMS           bool                     `json:"timeoutMaxTimeMS" toml:"timeoutMaxTimeMS"`                     // TimeoutMaxTimeMS 使用默认超时时间时是否同时设置服务端的maxTimeMS
+	TransactionMaxAttempts     int                      `json:"transactionMaxAttempts" toml:"transactionMaxAttempts"`         // TransactionMaxAttempts Client.Transaction遇到临时错误时最多尝试的次数，包括第一次
+	ReadDSNs                   []string                 `json:"readDsns" toml:"readDsns"`                                     // ReadDSNs 只读DSN地址，配置后Collection的Find、FindOne、Aggregate、CountDocuments、Distinct发送到只读连接，多个地址轮询使用
+	// 以下driver参数为空时使用DSN中的参数或者driver的默认值，不为空时优先于DSN中的参数
+	AppName                string               `json:"appName" toml:"appName"`                               // AppName 应用名称，会出现在服务端的日志和currentOp中
+	Compressors            []string             `json:"compressors" toml:"compressors"`                       // Compressors 压缩算法，按顺序与服务端协商，支持zstd、snappy、zlib
+	ReadPreference         ReadPreferenceConfig `json:"readPreference" toml:"readPreference"`                 // ReadPreference 读偏好
+	ReadConcern            string               `json:"readConcern" toml:"readConcern"`                       // ReadConcern 读关注级别，支持local、available、majority、linearizable、snapshot
+	WriteConcern           WriteConcernConfig   `json:"writeConcern" toml:"writeConcern"`                     // WriteConcern 写关注
+	RetryReads             *bool                `json:"retryReads" toml:"retryReads"`                         // RetryReads 读操作遇到网络错误时是否重试一次
+	RetryWrites            *bool                `json:"retryWrites" toml:"retryWrites"`                       // RetryWrites 写操作遇到网络错误时是否重试一次
+	ServerSelectionTimeout time.Duration        `json:"serverSelectionTimeout" toml:"serverSelectionTimeout"` // ServerSelectionTimeout 选择可用节点的超时时间
+	HeartbeatInterval      time.Duration        `json:"heartbeatInterval" toml:"heartbeatInterval"`           // HeartbeatInterval 心跳间隔，最小500ms
+	LocalThreshold         time.Duration        `json:"localThreshold" toml:"localThreshold"`                 // LocalThreshold 延迟在最快节点的LocalThreshold范围内的节点都可以被选中
+	ReplicaSet             string               `json:"replicaSet" toml:"replicaSet"`                         // ReplicaSet 副本集名称
+	Direct                 bool                 `json:"direct" toml:"direct"`                                 // Direct 是否直接连接DSN中的单个节点，不发现副本集中的其他节点
+	LoadBalanced           bool                 `json:"loadBalanced" toml:"loadBalanced"`                     // LoadBalanced 是否通过负载均衡器连接，不能与Direct、ReplicaSet同时使用
+	Authentication         Authentication
+	interceptors           []Interceptor
+	keyName                string
+	dbName                 string
+}
+
+// defaultDialTimeout dialTimeout为0时使用的连接超时
+const defaultDialTimeout = 10 * time.Second
+
+// dialTimeout 连接超时，为0时使用默认值，兼容没有配置dialTimeout的老配置
+func (config *config) dialTimeout() time.Duration {
+	if config.DialTimeout == 0 {
+		return defaultDialTimeout
+	}
+	return config.DialTimeout
 }
 
 // DefaultConfig 返回默认配置
@@ -35,9 +85,22 @@ func DefaultConfig() *config {
 		DialTimeout:             xtime.Duration("10s"),
 		SocketTimeout:           xtime.Duration("300s"),
 		SlowLogThreshold:        xtime.Duration("600ms"),
+		ShutdownTimeout:         xtime.Duration("5s"),
+		HealthProbeInterval:     xtime.Duration("10s"),
+		HealthProbeTimeout:      xtime.Duration("2s"),
 		MinPoolSize:             0,
 		MaxPoolSize:             300,
 		EnableMetricInterceptor: true,
 		EnableTraceInterceptor:  true,
+		EnableServerMonitor:     true,
+		TransactionMaxAttempts:  3,
+		RetryMaxAttempts:        3,
+		RetryMinBackoff:         xtime.Duration("50ms"),
+		RetryMaxBackoff:         xtime.Duration("1s"),
+		BreakerWindow:           xtime.Duration("10s"),
+		BreakerMinRequests:      20,
+		BreakerErrorRate:        0.5,
+		BreakerOpenTimeout:      xtime.Duration("30s"),
+		BreakerHalfOpenProbes:   3,
 	}
 }
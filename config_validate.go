@@ -0,0 +1,217 @@
+package emongo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// ErrDatabaseEmpty DSN中没有设置数据库
+var ErrDatabaseEmpty = errors.New("dsn has no database, e.g. mongodb://localhost:27017/mydb")
+
+// Validate 在不连接mongo的情况下校验配置，返回所有的问题，可以在单测或者命令行工具中使用
+func (config *config) Validate() error {
+	errs := config.validate()
+	if config.DSN != "" {
+		if cs, err := connstring.Parse(config.DSN); err == nil && cs.Database == "" {
+			errs = append(errs, ErrDatabaseEmpty)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validate Build时使用，为了兼容老版本，不校验DSN中的数据库
+func (config *config) validate() []error {
+	var errs []error
+	addErr := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	var dsn *connstring.ConnString
+	if config.DSN == "" {
+		addErr("dsn is required")
+	} else if cs, err := connstring.ParseAndValidate(config.DSN); err != nil {
+		addErr("invalid dsn: %w", err)
+	} else {
+		dsn = &cs
+	}
+	for i, dsn := range config.ReadDSNs {
+		if _, err := connstring.ParseAndValidate(dsn); err != nil {
+			addErr("invalid readDsns[%d]: %w", i, err)
+		}
+	}
+
+	if config.MinPoolSize < 0 {
+		addErr("minPoolSize must not be negative, got %d", config.MinPoolSize)
+	}
+	if config.MaxPoolSize < 0 {
+		addErr("maxPoolSize must not be negative, got %d", config.MaxPoolSize)
+	}
+	// maxPoolSize为0时连接数不限制
+	if config.MaxPoolSize > 0 && config.MinPoolSize > config.MaxPoolSize {
+		addErr("minPoolSize %d must not be greater than maxPoolSize %d", config.MinPoolSize, config.MaxPoolSize)
+	}
+
+	if config.EnableRetryInterceptor {
+		if config.RetryMaxAttempts < 1 {
+			addErr("retryMaxAttempts must be at least 1 when enableRetryInterceptor is true, got %d", config.RetryMaxAttempts)
+		}
+		if config.RetryMinBackoff <= 0 || config.RetryMaxBackoff < config.RetryMinBackoff {
+			addErr("retryMinBackoff %v must be positive and not greater than retryMaxBackoff %v", config.RetryMinBackoff, config.RetryMaxBackoff)
+		}
+	}
+	if config.EnableBreakerInterceptor {
+		if config.BreakerWindow <= 0 || config.BreakerOpenTimeout <= 0 {
+			addErr("breakerWindow %v and breakerOpenTimeout %v must be positive when enableBreakerInterceptor is true",
+				config.BreakerWindow, config.BreakerOpenTimeout)
+		}
+		if config.BreakerMinRequests < 1 || config.BreakerHalfOpenProbes < 1 {
+			addErr("breakerMinRequests %d and breakerHalfOpenProbes %d must be at least 1 when enableBreakerInterceptor is true",
+				config.BreakerMinRequests, config.BreakerHalfOpenProbes)
+		}
+		if config.BreakerErrorRate <= 0 || config.BreakerErrorRate > 1 {
+			addErr("breakerErrorRate must be in (0, 1], got %v", config.BreakerErrorRate)
+		}
+		if config.BreakerSlowThreshold < 0 {
+			addErr("breakerSlowThreshold must not be negative, got %v", config.BreakerSlowThreshold)
+		}
+	}
+	if config.EnableLimitInterceptor {
+		for i, rule := range config.Limits {
+			if err := rule.validate(); err != nil {
+				addErr("invalid limits[%d]: %w", i, err)
+			}
+		}
+	}
+	for name, d := range config.OperationTimeouts {
+		if d < 0 {
+			addErr("operationTimeouts[%s] must not be negative, got %v", name, d)
+		}
+	}
+	if config.TransactionMaxAttempts < 0 {
+		addErr("transactionMaxAttempts must not be negative, got %d", config.TransactionMaxAttempts)
+	}
+	if config.SocketTimeout <= 0 {
+		addErr("socketTimeout must be positive, got %v", config.SocketTimeout)
+	}
+	for _, field := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"dialTimeout", config.DialTimeout},
+		{"maxConnIdleTime", config.MaxConnIdleTime},
+		{"slowLogThreshold", config.SlowLogThreshold},
+		{"shutdownTimeout", config.ShutdownTimeout},
+		{"defaultTimeout", config.DefaultTimeout},
+		{"serverSelectionTimeout", config.ServerSelectionTimeout},
+		{"localThreshold", config.LocalThreshold},
+		{"writeConcern.wTimeout", config.WriteConcern.WTimeout},
+	} {
+		if field.value < 0 {
+			addErr("%s must not be negative, got %v", field.name, field.value)
+		}
+	}
+	if config.EnableHealthProbe {
+		if config.HealthProbeInterval <= 0 {
+			addErr("healthProbeInterval must be positive when enableHealthProbe is true, got %v", config.HealthProbeInterval)
+		}
+		if config.HealthProbeTimeout <= 0 || config.HealthProbeTimeout > config.HealthProbeInterval {
+			addErr("healthProbeTimeout %v must be positive and not greater than healthProbeInterval %v",
+				config.HealthProbeTimeout, config.HealthProbeInterval)
+		}
+	}
+	// socket超时后请求直接失败，等不到服务端返回写关注超时
+	if config.WriteConcern.WTimeout > 0 && config.WriteConcern.WTimeout >= config.SocketTimeout {
+		addErr("writeConcern.wTimeout %v must be less than socketTimeout %v", config.WriteConcern.WTimeout, config.SocketTimeout)
+	}
+
+	authErrs := config.Authentication.validate(dsn)
+	errs = append(errs, authErrs...)
+
+	// 总是校验driver参数，DSN、认证配置有误时已经报错，不再重复校验对应的部分
+	opts := options.Client()
+	if dsn != nil {
+		opts.ApplyURI(config.DSN)
+	}
+	if err := config.configureClientOptions(opts); err != nil {
+		addErr("invalid client options: %w", err)
+	}
+	if len(authErrs) == 0 {
+		if err := config.Authentication.ConfigureAuthentication(opts); err != nil {
+			addErr("invalid authentication: %w", err)
+		}
+	}
+	return errs
+}
+
+// validate dsn为nil时表示DSN有误，不再检查DSN中的TLS参数
+func (config *Authentication) validate(dsn *connstring.ConnString) []error {
+	var errs []error
+	addErr := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	tlsEnabled, hasCert := false, false
+	if tlsConfig := config.TLS; tlsConfig != nil && tlsConfig.Enabled {
+		tlsEnabled, hasCert = true, tlsConfig.CertFile != ""
+		for _, field := range [][2]string{
+			{"authentication.tls.caFile", tlsConfig.CAFile},
+			{"authentication.tls.certFile", tlsConfig.CertFile},
+			{"authentication.tls.keyFile", tlsConfig.KeyFile},
+		} {
+			if err := fileExists(field[1]); err != nil {
+				addErr("%s: %w", field[0], err)
+			}
+		}
+		if (tlsConfig.CertFile == "") != (tlsConfig.KeyFile == "") {
+			addErr("authentication.tls.certFile and keyFile must be set together")
+		}
+		if tlsConfig.ReloadInterval < 0 {
+			addErr("authentication.tls.reloadInterval must not be negative, got %v", tlsConfig.ReloadInterval)
+		}
+	} else if dsn != nil {
+		// 没有启用authentication.tls时使用DSN中的tls、tlsCertificateKeyFile等参数
+		tlsEnabled = dsn.SSL
+		hasCert = dsn.SSLClientCertificateKeyFileSet || dsn.SSLCertificateFileSet
+	}
+
+	if credential := config.Credential; credential != nil {
+		for _, field := range [][2]string{
+			{"authentication.credential.usernameFile", credential.UsernameFile},
+			{"authentication.credential.passwordFile", credential.PasswordFile},
+		} {
+			if err := fileExists(field[1]); err != nil {
+				addErr("%s: %w", field[0], err)
+			}
+		}
+		if credential.RotationInterval < 0 {
+			addErr("authentication.credential.rotationInterval must not be negative, got %v", credential.RotationInterval)
+		}
+		if credential.RotationInterval > 0 && credential.provider() == nil {
+			addErr("authentication.credential.rotationInterval requires usernameFile, passwordFile, usernameEnv or passwordEnv")
+		}
+		if strings.EqualFold(credential.Mechanism, "MONGODB-X509") && (!tlsEnabled || !hasCert) {
+			addErr("authentication.credential.mechanism MONGODB-X509 requires authentication.tls with certFile and keyFile, or tls and tlsCertificateKeyFile in dsn")
+		}
+	}
+	return errs
+}
+
+func fileExists(file string) error {
+	if file == "" {
+		return nil
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", file)
+	}
+	return nil
+}
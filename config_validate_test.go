@@ -0,0 +1,95 @@
+package emongo
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	config := DefaultConfig()
+	config.DSN = "mongodb://localhost:27017/test"
+	assert.NoError(t, config.Validate())
+
+	// 数据库为空只在Validate中报错，Build时为了兼容只打印日志
+	config.DSN = "mongodb://localhost:27017"
+	assert.ErrorIs(t, config.Validate(), ErrDatabaseEmpty)
+	assert.Empty(t, config.validate())
+
+	dir := t.TempDir()
+	config = DefaultConfig()
+	config.DSN = "mongodb://localhost:27017/test"
+	config.MinPoolSize = 10
+	config.MaxPoolSize = 5
+	config.DialTimeout = -time.Second
+	config.SlowLogThreshold = -time.Second
+	config.EnableHealthProbe = true
+	config.HealthProbeTimeout = time.Minute
+	config.WriteConcern.WTimeout = config.SocketTimeout
+	config.Authentication = Authentication{
+		TLS:        &TLSConfig{Enabled: true, CAFile: filepath.Join(dir, "ca.pem"), CertFile: filepath.Join(dir, "client.pem")},
+		Credential: &CredentialConfig{Mechanism: "MONGODB-X509", RotationInterval: time.Minute},
+	}
+	err := config.Validate()
+	require.Error(t, err)
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	for _, msg := range []string{
+		"minPoolSize 10 must not be greater than maxPoolSize 5",
+		"dialTimeout must not be negative",
+		"slowLogThreshold must not be negative",
+		"healthProbeTimeout 1m0s must be positive and not greater than healthProbeInterval 10s",
+		"writeConcern.wTimeout 5m0s must be less than socketTimeout 5m0s",
+		"authentication.tls.caFile",
+		"authentication.tls.certFile",
+		"authentication.tls.certFile and keyFile must be set together",
+		"authentication.credential.rotationInterval requires",
+	} {
+		assert.Contains(t, err.Error(), msg)
+	}
+	assert.Len(t, errs, 9)
+
+	config.Authentication = Authentication{Credential: &CredentialConfig{Mechanism: "MONGODB-X509"}}
+	assert.Contains(t, config.Validate().Error(), "MONGODB-X509 requires authentication.tls")
+
+	// DSN中配置了客户端证书
+	certFile := filepath.Join(dir, "client.pem")
+	cert := newTestCert(t, "client", nil, false)
+	writeFile(t, certFile, append(cert.pem, cert.kpem...))
+	config = DefaultConfig()
+	config.DSN = "mongodb://localhost:27017/test?tls=true&tlsCertificateKeyFile=" + certFile
+	config.Authentication = Authentication{Credential: &CredentialConfig{Mechanism: "MONGODB-X509"}}
+	assert.NoError(t, config.Validate())
+	config.DSN = "mongodb://localhost:27017/test?tls=true"
+	assert.Contains(t, config.Validate().Error(), "MONGODB-X509 requires authentication.tls")
+
+	// dialTimeout为0时使用默认值
+	config = DefaultConfig()
+	config.DSN = "mongodb://localhost:27017/test"
+	config.DialTimeout = 0
+	assert.NoError(t, config.Validate())
+	assert.Equal(t, defaultDialTimeout, config.dialTimeout())
+
+	config = DefaultConfig()
+	assert.EqualError(t, config.Validate(), "dsn is required")
+	config.DSN = "mongodb://localhost:27017/test?readPreference=unknown"
+	assert.Error(t, config.Validate())
+	config.DSN = "mongodb://localhost:27017/test"
+	config.Compressors = []string{"gzip"}
+	assert.Contains(t, config.Validate().Error(), "invalid client options")
+
+	// 有其他错误时依然校验driver参数
+	config.DialTimeout = -time.Second
+	errs = config.Validate().(interface{ Unwrap() []error }).Unwrap()
+	require.Len(t, errs, 2)
+	assert.Contains(t, errs[0].Error(), "dialTimeout must not be negative")
+	assert.Contains(t, errs[1].Error(), "invalid client options: unsupported compressor")
+
+	// DSN有误时不重复报告DSN的错误
+	config.DSN = "localhost:27017/test"
+	errs = config.Validate().(interface{ Unwrap() []error }).Unwrap()
+	require.Len(t, errs, 3)
+	assert.Contains(t, errs[0].Error(), "invalid dsn")
+}
@@ -2,12 +2,17 @@ package emongo
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gotomicro/ego/core/eapp"
 	"github.com/gotomicro/ego/core/econf"
 	"github.com/gotomicro/ego/core/elog"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
@@ -20,13 +25,24 @@ type Container struct {
 	config *config
 	name   string
 	logger *elog.Component
+	// fileConfig 上一次从配置文件中读取的配置，热更新时只覆盖配置文件中变化的配置，Option设置的配置保持不变
+	fileConfig *config
+	// stateful 有内部状态的拦截器，热更新时自身的配置没有变化则继续使用
+	stateful map[string]statefulInterceptor
+
+	tlsReloader        *tlsReloader
+	credentialProvider CredentialProvider
+	credential         *CredentialConfig
+	// mu Build之后，账号密码轮换和配置热更新都会重建driver client，保护config和credential
+	mu sync.Mutex
 }
 
 // DefaultContainer 返回默认Container
 func DefaultContainer() *Container {
 	return &Container{
-		config: DefaultConfig(),
-		logger: elog.EgoLogger.With(elog.FieldComponent(PackageName)),
+		config:   DefaultConfig(),
+		logger:   elog.EgoLogger.With(elog.FieldComponent(PackageName)),
+		stateful: make(map[string]statefulInterceptor),
 	}
 }
 
@@ -38,126 +54,273 @@ func Load(key string) *Container {
 		return c
 	}
 
+	fileConfig := *c.config
+	c.fileConfig = &fileConfig
 	c.logger = c.logger.With(elog.FieldComponentName(key))
 	c.name = key
 	return c
 }
 
-func (c *Container) newSession(config config) *Client {
-	// check config param
-	c.isConfigErr(config)
-
+// clientOptions 根据配置生成driver的ClientOptions，credential不为nil时覆盖配置和DSN中的账号密码
+// 返回的serverMonitor记录driver client的拓扑，用于健康检查，连接池和拓扑的日志、监控使用name区分不同的driver client
+func (c *Container) clientOptions(name string, config config, credential *CredentialConfig) (*options.ClientOptions, *serverMonitor, error) {
 	clientOpts := options.Client()
 
-	// 加载 TLS 配置
-	err := config.Authentication.ConfigureAuthentication(clientOpts)
-	if err != nil {
-		c.logger.Panic("mongo TLS configuration", elog.Any("authentication", config.Authentication), elog.Any("error", err))
-	}
-
-	if c.config.EnableTraceInterceptor {
+	if config.EnableTraceInterceptor {
 		clientOpts.Monitor = otelmongo.NewMonitor()
 	}
+	if config.EnablePoolMonitor {
+		clientOpts.SetPoolMonitor(newPoolMonitor(name))
+	}
+	monitor := newServerMonitor(name, c.logger, config.EnableServerMonitor)
+	clientOpts.SetServerMonitor(monitor.eventMonitor())
 	clientOpts.SetSocketTimeout(config.SocketTimeout)
 	clientOpts.SetMaxPoolSize(uint64(config.MaxPoolSize))
 	clientOpts.SetMinPoolSize(uint64(config.MinPoolSize))
 	clientOpts.SetMaxConnIdleTime(config.MaxConnIdleTime)
 
-	ctx, cancel := context.WithTimeoutCause(context.Background(), config.DialTimeout, fmt.Errorf("mongo dail %v timeout", config.DialTimeout))
-	defer cancel()
+	clientOpts.ApplyURI(config.DSN)
+	if err := config.configureClientOptions(clientOpts); err != nil {
+		return nil, nil, &ConfigError{Err: err}
+	}
 
-	client, err := Connect(ctx, clientOpts.ApplyURI(config.DSN))
-	if err != nil {
-		if c.config.OnFail == "panic" {
-			if eapp.IsDevelopmentMode() {
-				c.logger.Panic("dial mongo fail", elog.FieldErr(err), elog.FieldValueAny(c.config))
-			} else {
-				c.logger.Panic("dial mongo fail", elog.FieldErr(err), elog.FieldAddr(c.name))
-			}
-		} else {
-			c.logger.Error("dial mongo fail", elog.FieldErr(err), elog.FieldAddr(c.name))
-			return client
+	// 加载 TLS、账号认证配置，在DSN之后加载，配置中的值优先于DSN中的参数
+	if err := config.Authentication.ConfigureAuthentication(clientOpts); err != nil {
+		return nil, nil, &AuthError{Err: err}
+	}
+	if credential != nil {
+		if err := configureCredential(credential, clientOpts); err != nil {
+			return nil, nil, &AuthError{Err: err}
 		}
 	}
+	// 热更新修改了TLS配置时，使用新配置中的证书，不再由reloader替换
+	if c.tlsReloader != nil && clientOpts.TLSConfig != nil && reflect.DeepEqual(c.tlsReloader.config, config.Authentication.TLS) {
+		c.tlsReloader.apply(clientOpts.TLSConfig)
+	}
+	return clientOpts, monitor, nil
+}
+
+func (c *Container) newSession(config config) (*Client, error) {
+	var err error
+	if tlsConfig := config.Authentication.TLS; tlsConfig != nil && tlsConfig.Enabled && tlsConfig.ReloadInterval > 0 {
+		c.tlsReloader, err = newTLSReloader(tlsConfig, c.name, c.logger)
+		if err != nil {
+			return nil, &AuthError{Err: err}
+		}
+		c.tlsReloader.start()
+	}
+	if c.credentialProvider != nil {
+		c.credential, err = c.credentialProvider.Credential(context.Background())
+		if err != nil {
+			c.stopTLSReloader()
+			return nil, &AuthError{Err: err}
+		}
+	}
+
+	clientOpts, monitor, err := c.clientOptions(c.name, config, c.credential)
+	if err != nil {
+		c.stopTLSReloader()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeoutCause(context.Background(), config.dialTimeout(), fmt.Errorf("mongo dail %v timeout", config.dialTimeout()))
+	defer cancel()
+
+	// 连接失败时依然返回没有driver client的Client，请求返回ErrNotConnected，OnFail=lazy时由Build在后台重连
+	client := newClient(nil)
+	client.transactionMaxAttempts.Store(int32(config.TransactionMaxAttempts))
 	if eapp.IsDevelopmentMode() || c.config.Debug {
 		client.logMode = true
 	}
+	client.wrapProcessor(InterceptorChain(c.interceptors(c.config)...))
+
+	cc, err := mongo.Connect(ctx, clientOpts)
+	var readers []*mongo.Client
+	if err != nil {
+		err = &DialError{Err: err}
+	} else if readers, err = c.connectReaders(ctx, config, c.credential); err != nil {
+		_ = cc.Disconnect(context.Background())
+	} else {
+		client.conn.Store(&clientConn{cc: cc, monitor: monitor})
+		client.replaceReaders(readers, config.ShutdownTimeout)
+	}
+	if err != nil {
+		if config.OnFail != "lazy" {
+			c.stopTLSReloader()
+		}
+		return client, err
+	}
 
 	// 必须加入ping包，否则账号问题，需要发报文才能发现问题
 	ctx, cancel = context.WithTimeoutCause(context.Background(), 2*time.Second, fmt.Errorf("ping mongo 2s timeout"))
 	defer cancel()
-	err = client.Ping(ctx, readpref.Primary())
+	if err = client.Ping(ctx, readpref.Primary()); err != nil {
+		err = pingError(err)
+	} else {
+		err = pingReaders(ctx, readers)
+	}
 	if err != nil {
-		if c.config.OnFail == "panic" {
-			if eapp.IsDevelopmentMode() {
-				c.logger.Panic("ping mongo fail", elog.FieldErr(err), elog.FieldValueAny(c.config))
-			} else {
-				c.logger.Panic("ping mongo fail", elog.FieldErr(err), elog.FieldAddr(c.name))
-			}
-		} else {
-			c.logger.Error("ping mongo fail", elog.FieldErr(err), elog.FieldAddr(c.name))
-			return client
+		// lazy模式下ping成功才算连接成功，之前的请求返回ErrNotConnected，而不是等到服务端选择超时
+		if config.OnFail == "lazy" {
+			client.disconnect(context.Background())
 		}
+		return client, err
 	}
+	return client, nil
+}
 
-	//instances.Store(c.name, client)
-	client.wrapProcessor(InterceptorChain(config.interceptors...))
-	return client
+func (c *Container) stopTLSReloader() {
+	if c.tlsReloader != nil {
+		c.tlsReloader.close()
+		c.tlsReloader = nil
+	}
 }
 
-//var instances = sync.Map{}
-//
-//func iterate(fn func(name string, db *mongo.Client) bool) {
-//	instances.Range(func(key, val interface{}) bool {
-//		return fn(key.(string), val.(*mongo.Client))
-//	})
-//}
-//
-//func get(name string) *mongo.Client {
-//	if ins, ok := instances.Load(name); ok {
-//		return ins.(*mongo.Client)
-//	}
-//	return nil
-//}
+var instances = sync.Map{}
+
+// Range 遍历所有已经Build的Component，fn返回false时停止遍历
+func Range(fn func(name string, comp *Component) bool) {
+	instances.Range(func(key, val interface{}) bool {
+		return fn(key.(string), val.(*Component))
+	})
+}
 
-func (c *Container) isConfigErr(config config) {
-	if config.SocketTimeout == time.Duration(0) {
-		c.logger.Panic("invalid config", elog.FieldExtMessage("socketTimeout"))
+// Get 根据配置名称获取已经Build的Component，不存在时返回nil
+func Get(name string) *Component {
+	if ins, ok := instances.Load(name); ok {
+		return ins.(*Component)
 	}
+	return nil
 }
 
-// Build 构建Container
-func (c *Container) Build(options ...Option) *Component {
-	if options == nil {
-		options = make([]Option, 0)
+// Validate 在不连接mongo的情况下校验配置，返回所有的问题
+func (c *Container) Validate() error {
+	return c.config.Validate()
+}
+
+// interceptors 用户注入的拦截器在前，内置的拦截器在后
+// 内置的拦截器在请求时读取config，config创建后不能再修改，热更新时使用新的config重新生成
+func (c *Container) interceptors(config *config) []Interceptor {
+	interceptors := make([]Interceptor, 0, len(config.interceptors)+7)
+	interceptors = append(interceptors, config.interceptors...)
+	// 默认超时在最外层，access日志、限流等待和重试都在超时时间内
+	if config.DefaultTimeout > 0 || len(config.OperationTimeouts) > 0 {
+		interceptors = append(interceptors, timeoutInterceptor(config))
+	}
+	if config.Debug || eapp.IsDevelopmentMode() {
+		interceptors = append(interceptors, debugInterceptor(c.name, config))
+	}
+	if config.EnableMetricInterceptor {
+		interceptors = append(interceptors, metricInterceptor(c.name, config, c.logger))
 	}
-	if c.config.Debug || eapp.IsDevelopmentMode() {
-		options = append(options, WithInterceptor(debugInterceptor(c.name, c.config)))
+	if config.EnableAccessInterceptor {
+		interceptors = append(interceptors, accessInterceptor(c.name, config, c.logger))
 	}
-	if c.config.EnableMetricInterceptor {
-		options = append(options, WithInterceptor(metricInterceptor(c.name, c.config, c.logger)))
+	// 限流在熔断之外，熔断后的请求也需要经过限流
+	if config.EnableLimitInterceptor {
+		interceptors = append(interceptors, c.statefulInterceptor("limit", config.Limits, func() Interceptor {
+			return limitInterceptor(c.name, config)
+		}))
 	}
-	if c.config.EnableAccessInterceptor {
-		options = append(options, WithInterceptor(accessInterceptor(c.name, c.config, c.logger)))
+	// 熔断在重试之外，一次调用的多次重试只统计一次
+	if config.EnableBreakerInterceptor {
+		key := []interface{}{config.BreakerPerCollection, config.BreakerWindow, config.BreakerMinRequests, config.BreakerErrorRate,
+			config.BreakerSlowThreshold, config.BreakerOpenTimeout, config.BreakerHalfOpenProbes}
+		interceptors = append(interceptors, c.statefulInterceptor("breaker", key, func() Interceptor {
+			return breakerInterceptor(c.name, config)
+		}))
 	}
-	//if c.config.EnableTraceInterceptor {
-	// options = append(options, WithInterceptor(traceInterceptor(c.name, c.config, c.logger)))
+	// 重试在最内层，一次调用只记录一条access日志和监控，重试次数单独记录
+	if config.EnableRetryInterceptor {
+		interceptors = append(interceptors, retryInterceptor(c.name, config, c.logger))
+	}
+	//if config.EnableTraceInterceptor {
+	// interceptors = append(interceptors, traceInterceptor(c.name, config, c.logger))
 	//}
-	for _, option := range options {
-		option(c)
+	return interceptors
+}
+
+type statefulInterceptor struct {
+	key         interface{}
+	interceptor Interceptor
+}
+
+// statefulInterceptor 熔断、限流拦截器有内部状态，热更新时key没有变化则继续使用之前的拦截器
+// 避免无关的配置变化清空熔断状态和令牌桶，以及进行中的请求还持有旧的并发计数时并发数翻倍
+func (c *Container) statefulInterceptor(name string, key interface{}, build func() Interceptor) Interceptor {
+	if s, ok := c.stateful[name]; ok && reflect.DeepEqual(s.key, key) {
+		return s.interceptor
 	}
+	interceptor := build()
+	c.stateful[name] = statefulInterceptor{key: key, interceptor: interceptor}
+	return interceptor
+}
 
-	c.logger = c.logger.With(elog.FieldKey(c.name))
-	client := c.newSession(*c.config)
+// Build 构建Component，配置错误时panic，连接或者ping失败时根据OnFail决定panic、记录日志还是在后台重连
+func (c *Container) Build(options ...Option) *Component {
+	comp, err := c.build(options...)
+	if err == nil {
+		return comp
+	}
+	var dialErr *DialError
+	switch {
+	case comp != nil && c.config.OnFail == "lazy":
+		c.logger.Warn("connect mongo fail, reconnect in background", elog.FieldErr(err), elog.FieldAddr(c.name))
+	case errors.As(err, &dialErr):
+		c.onFail("dial mongo fail", err)
+		return comp
+	case comp == nil:
+		if eapp.IsDevelopmentMode() {
+			c.logger.Panic("build mongo fail", elog.FieldErr(err), elog.FieldValueAny(c.config))
+		} else {
+			c.logger.Panic("build mongo fail", elog.FieldErr(err), elog.FieldAddr(c.name))
+		}
+	default:
+		c.onFail("ping mongo fail", err)
+	}
+	return comp
+}
 
-	validateDsn, err := connstring.ParseAndValidate(c.config.DSN)
-	if err != nil {
+// BuildE 构建Component，不会panic，返回的错误为*ConfigError、*AuthError、*DialError、*PingError，可以通过errors.As区分
+// ConfigError、DialError以及加载证书、账号密码失败的AuthError返回的Component为nil
+// PingError以及服务端认证失败的AuthError返回的Component可以使用，由调用方决定是否降级
+// OnFail=lazy时DialError返回的Component也可以使用，在后台重连
+func (c *Container) BuildE(options ...Option) (*Component, error) {
+	comp, err := c.build(options...)
+	var dialErr *DialError
+	if errors.As(err, &dialErr) && c.config.OnFail != "lazy" {
+		return nil, err
+	}
+	return comp, err
+}
+
+func (c *Container) onFail(msg string, err error) {
+	if c.config.OnFail == "panic" {
 		if eapp.IsDevelopmentMode() {
-			c.logger.Panic("parse mongo dsn fail", elog.FieldErr(err), elog.FieldValueAny(c.config))
+			c.logger.Panic(msg, elog.FieldErr(err), elog.FieldValueAny(c.config))
 		} else {
-			c.logger.Panic("parse mongo dsn fail", elog.FieldErr(err), elog.FieldAddr(c.name))
+			c.logger.Panic(msg, elog.FieldErr(err), elog.FieldAddr(c.name))
 		}
+	} else {
+		c.logger.Error(msg, elog.FieldErr(err), elog.FieldAddr(c.name))
 	}
+}
+
+func (c *Container) build(options ...Option) (*Component, error) {
+	for _, option := range options {
+		option(c)
+	}
+	if c.credentialProvider == nil && c.config.Authentication.Credential != nil {
+		c.credentialProvider = c.config.Authentication.Credential.provider()
+	}
+
+	c.logger = c.logger.With(elog.FieldKey(c.name))
+
+	// 连接之前校验配置，一次返回所有的问题
+	if err := errors.Join(c.config.validate()...); err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+	validateDsn, _ := connstring.ParseAndValidate(c.config.DSN)
 	// 为了兼容之前的老版本，有的没设置dbName，不能panic。
 	if validateDsn.Database == "" {
 		c.logger.Error("database is empty")
@@ -165,9 +328,114 @@ func (c *Container) Build(options ...Option) *Component {
 	c.config.keyName = c.name + "." + validateDsn.Database
 	c.config.dbName = validateDsn.Database
 
-	return &Component{
-		config: c.config,
-		client: client,
-		logger: c.logger,
+	client, err := c.newSession(*c.config)
+	if client == nil {
+		return nil, err
+	}
+	// 不是lazy模式时DialError不重连，也不注册，Build返回的Component请求时返回ErrNotConnected
+	var dialErr *DialError
+	if errors.As(err, &dialErr) && c.config.OnFail != "lazy" {
+		comp := &Component{name: c.name, client: client, logger: c.logger}
+		comp.conf.Store(c.config)
+		return comp, err
+	}
+
+	comp := &Component{
+		name:        c.name,
+		client:      client,
+		logger:      c.logger,
+		tlsReloader: c.tlsReloader,
+	}
+	comp.conf.Store(c.config)
+	if c.config.EnableHealthProbe {
+		comp.startHealthProbe()
+	}
+	if client.Client() == nil {
+		comp.reconnector = &reconnector{
+			connect: func() error {
+				return c.connect(client)
+			},
+			minBackoff: reconnectMinBackoff,
+			maxBackoff: reconnectMaxBackoff,
+			name:       c.name,
+			logger:     c.logger,
+		}
+		comp.reconnector.start()
+	}
+	if credential := c.config.Authentication.Credential; c.credentialProvider != nil &&
+		credential != nil && credential.RotationInterval > 0 {
+		comp.credentialRotator = &credentialRotator{
+			provider: c.credentialProvider,
+			interval: credential.RotationInterval,
+			current:  c.credential,
+			rotate: func(credential *CredentialConfig) error {
+				return c.rotateCredential(client, credential)
+			},
+			name:   c.name,
+			logger: c.logger,
+		}
+		comp.credentialRotator.start()
+	}
+	// 没有名称的Component无法区分，不注册，也不监听配置变化
+	if c.name != "" {
+		c.watchConfig(comp)
+		comp.unwatch = c.unwatchConfig
+		if _, loaded := instances.Swap(c.name, comp); loaded {
+			c.logger.Warn("mongo component already registered, replace it")
+		}
+	}
+	return comp, err
+}
+
+// connect OnFail=lazy时后台连接，配置热更新或者账号密码轮换已经连接成功时直接返回
+func (c *Container) connect(client *Client) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if atomic.LoadInt32(&client.closed) == 1 {
+		return ErrClientClosed
+	}
+	if client.Client() != nil {
+		return nil
+	}
+	return c.replaceClient(client, c.config, c.credential)
+}
+
+// rotateCredential 使用新的账号密码创建driver client，ping成功后替换旧的client，旧的连接池在进行中的请求结束后关闭
+func (c *Container) rotateCredential(client *Client, credential *CredentialConfig) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.replaceClient(client, c.config, credential); err != nil {
+		return err
+	}
+	c.credential = credential
+	return nil
+}
+
+// replaceClient 使用config和credential创建driver client以及只读连接，ping成功后替换client中旧的driver client
+func (c *Container) replaceClient(client *Client, config *config, credential *CredentialConfig) error {
+	clientOpts, monitor, err := c.clientOptions(c.name, *config, credential)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), config.dialTimeout())
+	defer cancel()
+	cc, err := mongo.Connect(ctx, clientOpts)
+	if err != nil {
+		return err
+	}
+	if err = cc.Ping(ctx, readpref.Primary()); err != nil {
+		_ = cc.Disconnect(context.Background())
+		return err
+	}
+	readers, err := c.connectReaders(ctx, *config, credential)
+	if err == nil {
+		err = pingReaders(ctx, readers)
+	}
+	if err != nil {
+		disconnectAll(append(readers, cc))
+		return err
 	}
+	client.replaceClient(cc, monitor, config.ShutdownTimeout)
+	client.replaceReaders(readers, config.ShutdownTimeout)
+	return nil
 }
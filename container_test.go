@@ -0,0 +1,138 @@
+package emongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestContainer_BuildE(t *testing.T) {
+	c := DefaultContainer()
+	c.config.DSN = "mongodb://localhost:27017/test"
+	c.config.MinPoolSize = 10
+	c.config.MaxPoolSize = 5
+	comp, err := c.BuildE()
+	assert.Nil(t, comp)
+	var configErr *ConfigError
+	assert.True(t, errors.As(err, &configErr))
+	assert.Contains(t, err.Error(), "minPoolSize 10 must not be greater than maxPoolSize 5")
+
+	// 服务端不可达时返回PingError，Component依然可以使用
+	c = DefaultContainer()
+	c.config.DSN = "mongodb://127.0.0.1:1/test"
+	c.config.ServerSelectionTimeout = 200 * time.Millisecond
+	comp, err = c.BuildE()
+	require.NotNil(t, comp)
+	defer comp.Close(context.Background())
+	var pingErr *PingError
+	assert.True(t, errors.As(err, &pingErr))
+	assert.NotNil(t, comp.Client())
+}
+
+type staticCredentialProvider struct {
+	credential *CredentialConfig
+}
+
+func (p staticCredentialProvider) Credential(context.Context) (*CredentialConfig, error) {
+	return p.credential, nil
+}
+
+func TestContainer_BuildDialError(t *testing.T) {
+	// MONGODB-AWS的认证数据库只能是$external，mongo.Connect返回错误
+	newContainer := func() *Container {
+		c := DefaultContainer()
+		c.config.DSN = "mongodb://127.0.0.1:1/test"
+		c.config.OnFail = "error"
+		WithCredentialProvider(staticCredentialProvider{&CredentialConfig{Mechanism: "MONGODB-AWS", Source: "admin"}})(c)
+		return c
+	}
+	comp, err := newContainer().BuildE()
+	assert.Nil(t, comp)
+	var dialErr *DialError
+	assert.True(t, errors.As(err, &dialErr))
+
+	// Build返回的Component请求时返回ErrNotConnected，而不是panic
+	comp = newContainer().Build()
+	require.NotNil(t, comp)
+	require.NotNil(t, comp.Client())
+	assert.Nil(t, comp.reconnector)
+	_, err = comp.Client().Database("test").Collection("test").CountDocuments(context.Background(), bson.M{})
+	assert.ErrorIs(t, err, ErrNotConnected)
+	assert.Equal(t, ErrNotConnected.Error(), comp.Health(context.Background()).Err)
+	assert.NoError(t, comp.Close(context.Background()))
+}
+
+func TestContainer_BuildLazy(t *testing.T) {
+	c := DefaultContainer()
+	c.config.DSN = "mongodb://127.0.0.1:1/test"
+	c.config.ServerSelectionTimeout = 200 * time.Millisecond
+	c.config.OnFail = "lazy"
+	comp := c.Build()
+	require.NotNil(t, comp)
+	defer comp.Close(context.Background())
+	require.NotNil(t, comp.reconnector)
+	assert.Nil(t, comp.Client().Client())
+
+	coll := comp.Client().Database("test").Collection("test")
+	_, err := coll.CountDocuments(context.Background(), bson.M{})
+	assert.ErrorIs(t, err, ErrNotConnected)
+	assert.Nil(t, coll.Collection())
+	cur, err := coll.Indexes().List(context.Background())
+	assert.Nil(t, cur)
+	assert.ErrorIs(t, err, ErrNotConnected)
+	_, err = coll.Indexes().CreateOne(context.Background(), mongo.IndexModel{Keys: bson.D{{Key: "a", Value: 1}}})
+	assert.ErrorIs(t, err, ErrNotConnected)
+	assert.Equal(t, ErrNotConnected.Error(), comp.Health(context.Background()).Err)
+}
+
+func TestRangeAndGet(t *testing.T) {
+	build := func(name string) *Component {
+		c := DefaultContainer()
+		c.name = name
+		c.config.DSN = "mongodb://127.0.0.1:1/test"
+		c.config.ServerSelectionTimeout = 100 * time.Millisecond
+		c.config.OnFail = "lazy"
+		return c.Build()
+	}
+	ctx := context.Background()
+	comp1 := build("mongo.range1")
+	comp2 := build("mongo.range2")
+	defer comp2.Close(ctx)
+
+	assert.Equal(t, comp1, Get("mongo.range1"))
+	assert.Equal(t, comp2, Get("mongo.range2"))
+	assert.Nil(t, Get("mongo.notExist"))
+
+	got := make(map[string]*Component)
+	Range(func(name string, comp *Component) bool {
+		got[name] = comp
+		return true
+	})
+	assert.Equal(t, comp1, got["mongo.range1"])
+	assert.Equal(t, comp2, got["mongo.range2"])
+
+	// fn返回false时停止遍历
+	count := 0
+	Range(func(name string, comp *Component) bool {
+		count++
+		return false
+	})
+	assert.Equal(t, 1, count)
+
+	// 同名的Component替换旧的，旧的Close时不会删除新的
+	comp3 := build("mongo.range1")
+	defer comp3.Close(ctx)
+	assert.Equal(t, comp3, Get("mongo.range1"))
+	assert.NoError(t, comp1.Close(ctx))
+	assert.Equal(t, comp3, Get("mongo.range1"))
+
+	// Close后不能再获取到
+	assert.NoError(t, comp3.Close(ctx))
+	assert.Nil(t, Get("mongo.range1"))
+}
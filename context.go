@@ -0,0 +1,90 @@
+package emongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+type callOptionsKey struct{}
+
+// callOptions 通过ctx设置的单次请求参数，优先于Collection、Database以及配置中的参数
+type callOptions struct {
+	readPreference *readpref.ReadPref
+	readConcern    *readconcern.ReadConcern
+	writeConcern   *writeconcern.WriteConcern
+	maxTime        time.Duration
+}
+
+func withCallOptions(ctx context.Context, fn func(o *callOptions)) context.Context {
+	var o callOptions
+	if old := callOptionsFromContext(ctx); old != nil {
+		o = *old
+	}
+	fn(&o)
+	return context.WithValue(ctx, callOptionsKey{}, &o)
+}
+
+func callOptionsFromContext(ctx context.Context) *callOptions {
+	if ctx == nil {
+		return nil
+	}
+	o, _ := ctx.Value(callOptionsKey{}).(*callOptions)
+	return o
+}
+
+// WithReadPreference 使用ctx的Collection请求使用rp读取，事务中的请求使用事务的读偏好
+func WithReadPreference(ctx context.Context, rp *readpref.ReadPref) context.Context {
+	return withCallOptions(ctx, func(o *callOptions) { o.readPreference = rp })
+}
+
+// WithReadConcern 使用ctx的Collection请求使用rc读取，事务中的请求使用事务的读关注
+func WithReadConcern(ctx context.Context, rc *readconcern.ReadConcern) context.Context {
+	return withCallOptions(ctx, func(o *callOptions) { o.readConcern = rc })
+}
+
+// WithWriteConcern 使用ctx的Collection请求使用wc写入，事务中的请求使用事务的写关注
+func WithWriteConcern(ctx context.Context, wc *writeconcern.WriteConcern) context.Context {
+	return withCallOptions(ctx, func(o *callOptions) { o.writeConcern = wc })
+}
+
+// WithMaxTime 使用ctx的Collection查询在服务端的最长执行时间(maxTimeMS)，调用方传入的options中设置了MaxTime时以options为准
+// 只对Aggregate、CountDocuments、Distinct、EstimatedDocumentCount、Find、FindOne、FindOneAndXxx生效
+func WithMaxTime(ctx context.Context, d time.Duration) context.Context {
+	return withCallOptions(ctx, func(o *callOptions) { o.maxTime = d })
+}
+
+// applyCallOptions ctx中设置了读偏好、读写关注时返回使用这些参数的mongo.Collection
+func applyCallOptions(ctx context.Context, coll *mongo.Collection) *mongo.Collection {
+	o := callOptionsFromContext(ctx)
+	if o == nil || coll == nil || (o.readPreference == nil && o.readConcern == nil && o.writeConcern == nil) {
+		return coll
+	}
+	collOpts := options.Collection()
+	if o.readPreference != nil {
+		collOpts.SetReadPreference(o.readPreference)
+	}
+	if o.readConcern != nil {
+		collOpts.SetReadConcern(o.readConcern)
+	}
+	if o.writeConcern != nil {
+		collOpts.SetWriteConcern(o.writeConcern)
+	}
+	// Clone只复制参数，不会返回错误
+	coll, _ = coll.Clone(collOpts)
+	return coll
+}
+
+// maxTimeOptions ctx中设置了maxTime时，在opts之前加入设置了MaxTime的options，调用方传入的options优先
+func maxTimeOptions[T any](ctx context.Context, opts []*T, newOpts func(d time.Duration) *T) []*T {
+	o := callOptionsFromContext(ctx)
+	if o == nil || o.maxTime <= 0 {
+		return opts
+	}
+	return append([]*T{newOpts(o.maxTime)}, opts...)
+}
@@ -0,0 +1,81 @@
+package emongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/description"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+func TestCallOptions(t *testing.T) {
+	client, md := newMockClient(t,
+		cursorResponse("test.users", 0, "firstBatch"),
+		bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}},
+		cursorResponse("test.users", 0, "firstBatch"),
+	)
+	md.setServerKind(description.Mongos)
+	coll := client.Database("test").Collection("users")
+
+	ctx := WithReadPreference(context.Background(), readpref.SecondaryPreferred())
+	ctx = WithReadConcern(ctx, readconcern.Majority())
+	ctx = WithWriteConcern(ctx, writeconcern.New(writeconcern.WMajority()))
+	ctx = WithMaxTime(ctx, time.Second)
+	cur, err := coll.Find(ctx, bson.M{})
+	require.NoError(t, err)
+	require.NoError(t, cur.Close(ctx))
+	_, err = coll.InsertOne(ctx, bson.M{"a": 1})
+	require.NoError(t, err)
+	// 原来的Collection不受影响
+	cur, err = coll.Find(context.Background(), bson.M{})
+	require.NoError(t, err)
+	require.NoError(t, cur.Close(ctx))
+
+	sent := md.sent()
+	require.Len(t, sent, 3)
+	find := sent[0]
+	assert.Equal(t, "secondaryPreferred", find.Lookup("$readPreference", "mode").StringValue())
+	assert.Equal(t, "majority", find.Lookup("readConcern", "level").StringValue())
+	assert.Equal(t, int64(1000), find.Lookup("maxTimeMS").AsInt64())
+	assert.Equal(t, "majority", sent[1].Lookup("writeConcern", "w").StringValue())
+	find = sent[2]
+	assert.Empty(t, find.Lookup("$readPreference").Value)
+	assert.Empty(t, find.Lookup("readConcern").Value)
+	assert.Empty(t, find.Lookup("maxTimeMS").Value)
+
+	opts := maxTimeOptions(ctx, []*options.FindOptions{options.Find().SetMaxTime(2 * time.Second)}, options.Find().SetMaxTime)
+	require.Len(t, opts, 2)
+	assert.Equal(t, 2*time.Second, *options.MergeFindOptions(opts...).MaxTime)
+	opts = maxTimeOptions(ctx, nil, options.Find().SetMaxTime)
+	assert.Equal(t, time.Second, *options.MergeFindOptions(opts...).MaxTime)
+	assert.Empty(t, maxTimeOptions(context.Background(), []*options.FindOptions(nil), options.Find().SetMaxTime))
+}
+
+func TestCollection_ReadCollectionPrimaryPreference(t *testing.T) {
+	client, primary := newMockClient(t, cursorResponse("test.users", 0, "firstBatch"))
+	readerClient, reader := newMockClient(t, cursorResponse("test.users", 0, "firstBatch"))
+	reader.setServerKind(description.Mongos)
+	client.replaceReaders([]*mongo.Client{readerClient.Client()}, 0)
+	coll := client.Database("test").Collection("users")
+
+	cur, err := coll.Find(WithReadPreference(context.Background(), readpref.Primary()), bson.M{})
+	require.NoError(t, err)
+	require.NoError(t, cur.Close(context.Background()))
+	assert.Len(t, primary.sent(), 1)
+	assert.Empty(t, reader.sent())
+
+	cur, err = coll.Find(WithReadPreference(context.Background(), readpref.Nearest()), bson.M{})
+	require.NoError(t, err)
+	require.NoError(t, cur.Close(context.Background()))
+	assert.Len(t, primary.sent(), 1)
+	require.Len(t, reader.sent(), 1)
+	assert.Equal(t, "nearest", reader.sent()[0].Lookup("$readPreference", "mode").StringValue())
+}
@@ -0,0 +1,152 @@
+package emongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotomicro/ego/core/elog"
+)
+
+// CredentialProvider 账号认证信息的来源，Build时以及开启轮换后定时调用
+// 返回的字段不为空时覆盖Authentication.Credential以及DSN中的参数
+type CredentialProvider interface {
+	Credential(ctx context.Context) (*CredentialConfig, error)
+}
+
+type fileCredentialProvider struct {
+	usernameFile string
+	passwordFile string
+}
+
+// NewFileCredentialProvider 从文件中读取用户名和密码，例如挂载的secret文件，文件名为空时不读取对应的字段
+func NewFileCredentialProvider(usernameFile, passwordFile string) CredentialProvider {
+	return &fileCredentialProvider{usernameFile: usernameFile, passwordFile: passwordFile}
+}
+
+func (p *fileCredentialProvider) Credential(ctx context.Context) (*CredentialConfig, error) {
+	username, err := readSecretFile(p.usernameFile)
+	if err != nil {
+		return nil, err
+	}
+	password, err := readSecretFile(p.passwordFile)
+	if err != nil {
+		return nil, err
+	}
+	return &CredentialConfig{Username: username, Password: password}, nil
+}
+
+// readSecretFile 读取文件内容，去掉首尾的空白字符
+func readSecretFile(file string) (string, error) {
+	if file == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return "", fmt.Errorf("failed to read credential file %s: %w", file, err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", fmt.Errorf("credential file %s is empty", file)
+	}
+	return value, nil
+}
+
+type envCredentialProvider struct {
+	usernameEnv string
+	passwordEnv string
+}
+
+// NewEnvCredentialProvider 从环境变量中读取用户名和密码，环境变量名为空时不读取对应的字段
+func NewEnvCredentialProvider(usernameEnv, passwordEnv string) CredentialProvider {
+	return &envCredentialProvider{usernameEnv: usernameEnv, passwordEnv: passwordEnv}
+}
+
+func (p *envCredentialProvider) Credential(ctx context.Context) (*CredentialConfig, error) {
+	username, err := lookupEnv(p.usernameEnv)
+	if err != nil {
+		return nil, err
+	}
+	password, err := lookupEnv(p.passwordEnv)
+	if err != nil {
+		return nil, err
+	}
+	return &CredentialConfig{Username: username, Password: password}, nil
+}
+
+func lookupEnv(key string) (string, error) {
+	if key == "" {
+		return "", nil
+	}
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return "", fmt.Errorf("credential environment variable %s is not set", key)
+	}
+	return value, nil
+}
+
+// credentialRotator 定时从CredentialProvider读取账号密码，变化后重建driver client
+type credentialRotator struct {
+	provider CredentialProvider
+	interval time.Duration
+	current  *CredentialConfig
+	rotate   func(credential *CredentialConfig) error
+	name     string
+	logger   *elog.Component
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// check 账号密码变化时调用rotate，失败时继续使用旧的连接池，下次检查时重试
+func (r *credentialRotator) check(ctx context.Context) {
+	credential, err := r.provider.Credential(ctx)
+	if err == nil && reflect.DeepEqual(credential, r.current) {
+		return
+	}
+	if err == nil {
+		if credential == nil {
+			err = errors.New("credential provider returned nil credential")
+		} else {
+			err = r.rotate(credential)
+		}
+	}
+	if err != nil {
+		CredentialRotationCounter.WithLabelValues(metricType, r.name, "fail").Inc()
+		r.logger.Error("mongo credential rotate fail", elog.FieldErr(err))
+		return
+	}
+	r.current = credential
+	CredentialRotationCounter.WithLabelValues(metricType, r.name, "success").Inc()
+	r.logger.Info("mongo credential rotated", elog.String("username", credential.Username))
+}
+
+func (r *credentialRotator) start() {
+	r.stop = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.check(context.Background())
+			}
+		}
+	}()
+}
+
+func (r *credentialRotator) close() {
+	r.stopOnce.Do(func() {
+		if r.stop != nil {
+			close(r.stop)
+		}
+	})
+}
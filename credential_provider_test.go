@@ -0,0 +1,84 @@
+package emongo
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileCredentialProvider(t *testing.T) {
+	dir := t.TempDir()
+	usernameFile, passwordFile := filepath.Join(dir, "username"), filepath.Join(dir, "password")
+	writeFile(t, usernameFile, []byte("user\n"))
+	writeFile(t, passwordFile, []byte("secret\n"))
+
+	credential, err := NewFileCredentialProvider(usernameFile, passwordFile).Credential(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &CredentialConfig{Username: "user", Password: "secret"}, credential)
+
+	// 只读取密码，用户名使用配置或者DSN中的值
+	credential, err = NewFileCredentialProvider("", passwordFile).Credential(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &CredentialConfig{Password: "secret"}, credential)
+
+	writeFile(t, passwordFile, []byte("\n"))
+	_, err = NewFileCredentialProvider(usernameFile, passwordFile).Credential(context.Background())
+	assert.Error(t, err)
+	_, err = NewFileCredentialProvider(usernameFile, filepath.Join(dir, "missing")).Credential(context.Background())
+	assert.Error(t, err)
+}
+
+func TestEnvCredentialProvider(t *testing.T) {
+	t.Setenv("EMONGO_TEST_USERNAME", "user")
+	t.Setenv("EMONGO_TEST_PASSWORD", "secret")
+	credential, err := NewEnvCredentialProvider("EMONGO_TEST_USERNAME", "EMONGO_TEST_PASSWORD").Credential(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &CredentialConfig{Username: "user", Password: "secret"}, credential)
+
+	_, err = NewEnvCredentialProvider("EMONGO_TEST_USERNAME", "EMONGO_TEST_MISSING").Credential(context.Background())
+	assert.Error(t, err)
+
+	provider := (&CredentialConfig{PasswordFile: "password", PasswordEnv: "EMONGO_TEST_PASSWORD"}).provider()
+	assert.IsType(t, &fileCredentialProvider{}, provider)
+	assert.Nil(t, (&CredentialConfig{Username: "user"}).provider())
+}
+
+func TestCredentialRotator(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	writeFile(t, passwordFile, []byte("v1"))
+	provider := NewFileCredentialProvider("", passwordFile)
+	current, err := provider.Credential(context.Background())
+	require.NoError(t, err)
+
+	var rotated []string
+	var rotateErr error
+	rotator := &credentialRotator{
+		provider: provider,
+		current:  current,
+		rotate: func(credential *CredentialConfig) error {
+			rotated = append(rotated, credential.Password)
+			return rotateErr
+		},
+		name:   "mongo",
+		logger: testLogger,
+	}
+
+	// 没有变化时不重建
+	rotator.check(context.Background())
+	assert.Empty(t, rotated)
+
+	// 重建失败时下次检查重试
+	writeFile(t, passwordFile, []byte("v2"))
+	rotateErr = errors.New("auth fail")
+	rotator.check(context.Background())
+	rotateErr = nil
+	rotator.check(context.Background())
+	rotator.check(context.Background())
+	assert.Equal(t, []string{"v2", "v2"}, rotated)
+	assert.Equal(t, "v2", rotator.current.Password)
+}
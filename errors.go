@@ -0,0 +1,65 @@
+package emongo
+
+import (
+	"errors"
+
+	"go.mongodb.org/mongo-driver/x/mongo/driver/auth"
+)
+
+var (
+	// ErrClientClosed Component已经停止，不再接受新的请求
+	ErrClientClosed = errors.New("emongo: client is closed")
+	// ErrCircuitOpen 熔断器打开，请求没有发送到服务端
+	ErrCircuitOpen = errors.New("emongo: circuit breaker is open")
+	// ErrRateLimited 超过限流规则的请求速率
+	ErrRateLimited = errors.New("emongo: rate limited")
+	// ErrConcurrencyLimited 超过限流规则的并发数
+	ErrConcurrencyLimited = errors.New("emongo: too many requests in flight")
+	// ErrNotConnected OnFail=lazy时，后台还没有连接成功
+	ErrNotConnected = errors.New("emongo: client is not connected")
+	// ErrCursorNotClosed 游标没有调用Close就被回收
+	ErrCursorNotClosed = errors.New("emongo: cursor garbage collected without Close")
+)
+
+// ConfigError 配置错误，例如DSN格式错误、参数不合法，BuildE返回的Component为nil
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string { return "emongo: invalid config: " + e.Err.Error() }
+func (e *ConfigError) Unwrap() error { return e.Err }
+
+// AuthError TLS证书、账号密码加载失败，或者ping时服务端认证失败
+// 加载失败时BuildE返回的Component为nil，认证失败时返回的Component可以使用，账号密码修正后会自动恢复
+type AuthError struct {
+	Err error
+}
+
+func (e *AuthError) Error() string { return "emongo: authentication fail: " + e.Err.Error() }
+func (e *AuthError) Unwrap() error { return e.Err }
+
+// DialError 创建driver client失败，BuildE返回的Component为nil
+type DialError struct {
+	Err error
+}
+
+func (e *DialError) Error() string { return "emongo: dial fail: " + e.Err.Error() }
+func (e *DialError) Unwrap() error { return e.Err }
+
+// PingError 连接建立后ping失败，通常是网络不通或者没有可用的primary
+// BuildE返回的Component可以使用，driver会在后台继续重连
+type PingError struct {
+	Err error
+}
+
+func (e *PingError) Error() string { return "emongo: ping fail: " + e.Err.Error() }
+func (e *PingError) Unwrap() error { return e.Err }
+
+// pingError 区分认证失败和其他的ping失败
+func pingError(err error) error {
+	var authErr *auth.Error
+	if errors.As(err, &authErr) {
+		return &AuthError{Err: err}
+	}
+	return &PingError{Err: err}
+}
@@ -0,0 +1,170 @@
+package emongo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/gotomicro/ego/server/egovernor"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func init() {
+	egovernor.HandleFunc("/debug/mongo/health", healthHandler)
+}
+
+// Health 健康检查结果
+type Health struct {
+	Name               string        `json:"name"`
+	PrimaryReachable   bool          `json:"primaryReachable"`   // PrimaryReachable primary是否可以ping通
+	SecondaryReachable bool          `json:"secondaryReachable"` // SecondaryReachable driver最新的拓扑中是否有心跳成功的secondary，单机部署时为false
+	ReplicationLag     time.Duration `json:"replicationLag"`     // ReplicationLag 落后最多的secondary与primary的复制延迟，没有权限执行replSetGetStatus时为0
+	LastPingTime       time.Time     `json:"lastPingTime"`       // LastPingTime 最近一次成功ping primary的时间
+	CheckTime          time.Time     `json:"checkTime"`          // CheckTime 本次检查的时间
+	Err                string        `json:"err,omitempty"`      // Err ping primary失败的原因
+}
+
+// Healthy primary可以ping通时认为健康
+func (h Health) Healthy() bool {
+	return h.PrimaryReachable
+}
+
+// Health 立即执行一次健康检查
+func (c *Component) Health(ctx context.Context) Health {
+	health := c.checkHealth(ctx)
+	c.healthMu.Lock()
+	if !health.PrimaryReachable {
+		health.LastPingTime = c.health.LastPingTime
+	}
+	c.health = health
+	c.healthMu.Unlock()
+	return health
+}
+
+// LastHealth 返回最近一次健康检查的结果，开启EnableHealthProbe后由后台定时更新
+func (c *Component) LastHealth() Health {
+	c.healthMu.RLock()
+	defer c.healthMu.RUnlock()
+	return c.health
+}
+
+// checkHealth 直接使用driver检查，不经过拦截器，避免探测请求影响业务的日志和监控
+func (c *Component) checkHealth(ctx context.Context) Health {
+	health := Health{Name: c.name, CheckTime: time.Now()}
+	if c.client == nil {
+		health.Err = "mongo client is nil"
+		return health
+	}
+	conn := c.client.conn.Load()
+	if conn == nil {
+		health.Err = ErrNotConnected.Error()
+		return health
+	}
+	cc := conn.cc
+
+	pingCtx, cancel := context.WithTimeout(ctx, c.config().HealthProbeTimeout)
+	defer cancel()
+	if err := cc.Ping(pingCtx, readpref.Primary()); err != nil {
+		health.Err = err.Error()
+	} else {
+		health.PrimaryReachable = true
+		health.LastPingTime = time.Now()
+	}
+
+	// 单机部署满足任意读偏好，不能通过ping secondary判断
+	health.SecondaryReachable = conn.monitor != nil && conn.monitor.hasSecondary()
+
+	if health.PrimaryReachable && health.SecondaryReachable {
+		pingCtx, cancel = context.WithTimeout(ctx, c.config().HealthProbeTimeout)
+		defer cancel()
+		health.ReplicationLag = replicationLag(pingCtx, cc)
+	}
+	return health
+}
+
+func replicationLag(ctx context.Context, cc *mongo.Client) time.Duration {
+	var status struct {
+		Members []struct {
+			StateStr   string    `bson:"stateStr"`
+			OptimeDate time.Time `bson:"optimeDate"`
+		} `bson:"members"`
+	}
+	err := cc.Database("admin").RunCommand(ctx, bson.D{{Key: "replSetGetStatus", Value: 1}}).Decode(&status)
+	if err != nil {
+		return 0
+	}
+	var primary, oldest time.Time
+	for _, member := range status.Members {
+		switch member.StateStr {
+		case "PRIMARY":
+			primary = member.OptimeDate
+		case "SECONDARY":
+			if oldest.IsZero() || member.OptimeDate.Before(oldest) {
+				oldest = member.OptimeDate
+			}
+		}
+	}
+	if primary.IsZero() || oldest.IsZero() || oldest.After(primary) {
+		return 0
+	}
+	return primary.Sub(oldest)
+}
+
+func (c *Component) startHealthProbe() {
+	c.healthStop = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(c.config().HealthProbeInterval)
+		defer ticker.Stop()
+		healthy := true
+		for {
+			health := c.Health(context.Background())
+			if health.Healthy() != healthy {
+				healthy = health.Healthy()
+				if healthy {
+					c.logger.Info("mongo health recovered", elog.FieldValueAny(health))
+				} else {
+					c.logger.Error("mongo health check fail", elog.FieldValueAny(health))
+				}
+			}
+			select {
+			case <-c.healthStop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (c *Component) stopHealthProbe() {
+	c.healthOnce.Do(func() {
+		if c.healthStop != nil {
+			close(c.healthStop)
+		}
+	})
+}
+
+// healthHandler 返回所有Component的健康状态，有任意一个不健康时返回503，可以直接作为readiness探针
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	list := make([]Health, 0)
+	code := http.StatusOK
+	Range(func(name string, comp *Component) bool {
+		var health Health
+		if comp.config().EnableHealthProbe {
+			health = comp.LastHealth()
+		} else {
+			health = comp.Health(r.Context())
+		}
+		if !health.Healthy() {
+			code = http.StatusServiceUnavailable
+		}
+		list = append(list, health)
+		return true
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,108 @@
+package emongo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gotomicro/ego/server/egovernor"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/description"
+)
+
+func topologyChanged(m *serverMonitor, kind description.TopologyKind, servers ...description.ServerKind) {
+	topology := description.Topology{Kind: kind}
+	for _, server := range servers {
+		topology.Servers = append(topology.Servers, description.Server{Kind: server})
+	}
+	m.eventMonitor().TopologyDescriptionChanged(&event.TopologyDescriptionChangedEvent{NewDescription: topology})
+}
+
+func TestServerMonitor_HasSecondary(t *testing.T) {
+	m := newServerMonitor("test", testLogger, false)
+	assert.False(t, m.hasSecondary())
+
+	// 单机部署没有secondary
+	topologyChanged(m, description.Single, description.Standalone)
+	assert.False(t, m.hasSecondary())
+
+	topologyChanged(m, description.ReplicaSetWithPrimary, description.RSPrimary, description.RSSecondary)
+	assert.True(t, m.hasSecondary())
+
+	// secondary心跳失败后变为Unknown
+	topologyChanged(m, description.ReplicaSetWithPrimary, description.RSPrimary, description.Unknown)
+	assert.False(t, m.hasSecondary())
+}
+
+func newHealthComponent(t *testing.T, name string) (*Component, *mockDeployment) {
+	client, md := newMockClient(t)
+	conn := client.conn.Load()
+	conn.monitor = newServerMonitor(name, testLogger, false)
+	topologyChanged(conn.monitor, description.Single, description.Standalone)
+	comp := &Component{name: name, client: client, logger: testLogger}
+	comp.conf.Store(DefaultConfig())
+	return comp, md
+}
+
+func TestComponent_Health(t *testing.T) {
+	comp, md := newHealthComponent(t, "mongo.health")
+	ctx := context.Background()
+
+	md.addResponses(bson.D{{Key: "ok", Value: 1}})
+	health := comp.Health(ctx)
+	assert.True(t, health.Healthy())
+	assert.False(t, health.SecondaryReachable)
+	assert.Empty(t, health.Err)
+	lastPingTime := health.LastPingTime
+	assert.False(t, lastPingTime.IsZero())
+
+	// ping失败时保留上一次成功的时间
+	md.addResponses(bson.D{{Key: "ok", Value: 0}, {Key: "code", Value: 13}, {Key: "errmsg", Value: "unauthorized"}})
+	health = comp.Health(ctx)
+	assert.False(t, health.Healthy())
+	assert.Contains(t, health.Err, "unauthorized")
+	assert.Equal(t, lastPingTime, health.LastPingTime)
+	assert.Equal(t, health, comp.LastHealth())
+}
+
+func TestHealthHandler(t *testing.T) {
+	comp, md := newHealthComponent(t, "mongo.healthHandler")
+	instances.Store(comp.name, comp)
+	defer instances.Delete(comp.name)
+
+	// init中注册到governor
+	get := func() (int, []Health) {
+		w := httptest.NewRecorder()
+		egovernor.DefaultServeMux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/mongo/health", nil))
+		var list []Health
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		for _, health := range list {
+			if health.Name == comp.name {
+				return w.Code, []Health{health}
+			}
+		}
+		return w.Code, nil
+	}
+
+	md.addResponses(bson.D{{Key: "ok", Value: 1}})
+	code, list := get()
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, list, 1) {
+		assert.True(t, list[0].PrimaryReachable)
+	}
+
+	// 开启后台探测时返回最近一次的结果，不再访问服务端
+	config := DefaultConfig()
+	config.EnableHealthProbe = true
+	comp.conf.Store(config)
+	comp.health = Health{Name: comp.name, Err: "probe fail"}
+	code, list = get()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "probe fail", list[0].Err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -13,6 +14,7 @@ import (
 	"github.com/gotomicro/ego/core/eapp"
 	"github.com/gotomicro/ego/core/elog"
 	"github.com/gotomicro/ego/core/emetric"
+	"github.com/gotomicro/ego/core/etrace"
 	"github.com/gotomicro/ego/core/util/xdebug"
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -21,14 +23,15 @@ const (
 	metricType = "mongo"
 )
 
-type Interceptor func(oldProcessFn processFn) (newProcessFn processFn)
+// Interceptor 拦截器，通过包装ProcessFn，可以在执行前后读取Cmd中的ctx、操作名、库名、集合名、查询条件等信息
+type Interceptor func(oldProcessFn ProcessFn) (newProcessFn ProcessFn)
 
-func InterceptorChain(interceptors ...Interceptor) func(oldProcess processFn) processFn {
-	build := func(interceptor Interceptor, oldProcess processFn) processFn {
+func InterceptorChain(interceptors ...Interceptor) func(oldProcess ProcessFn) ProcessFn {
+	build := func(interceptor Interceptor, oldProcess ProcessFn) ProcessFn {
 		return interceptor(oldProcess)
 	}
 
-	return func(oldProcess processFn) processFn {
+	return func(oldProcess ProcessFn) ProcessFn {
 		chain := oldProcess
 		for i := len(interceptors) - 1; i >= 0; i-- {
 			chain = build(interceptors[i], chain)
@@ -37,9 +40,9 @@ func InterceptorChain(interceptors ...Interceptor) func(oldProcess processFn) pr
 	}
 }
 
-func debugInterceptor(compName string, c *config) func(processFn) processFn {
-	return func(oldProcess processFn) processFn {
-		return func(cmd *cmd) error {
+func debugInterceptor(compName string, c *config) func(ProcessFn) ProcessFn {
+	return func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
 			if !eapp.IsDevelopmentMode() {
 				return oldProcess(cmd)
 			}
@@ -49,11 +52,11 @@ func debugInterceptor(compName string, c *config) func(processFn) processFn {
 			cost := time.Since(beg)
 			if err != nil {
 				log.Println("emongo.response", xdebug.MakeReqAndResError(fileWithLineNum(), compName,
-					fmt.Sprintf("%v", c.keyName), cost, fmt.Sprintf("%s %v", cmd.name, mustJsonMarshal(cmd.req)), err.Error()),
+					fmt.Sprintf("%v", c.keyName), cost, fmt.Sprintf("%s %v", cmd.Name, mustJsonMarshal(cmd.Req)), err.Error()),
 				)
 			} else {
 				log.Println("emongo.response", xdebug.MakeReqAndResInfo(fileWithLineNum(), compName,
-					fmt.Sprintf("%v", c.keyName), cost, fmt.Sprintf("%s %v", cmd.name, mustJsonMarshal(cmd.req)), fmt.Sprintf("%v", cmd.res)),
+					fmt.Sprintf("%v", c.keyName), cost, fmt.Sprintf("%s %v", cmd.Name, mustJsonMarshal(cmd.Req)), fmt.Sprintf("%v", cmd.Res)),
 				)
 			}
 			return err
@@ -61,47 +64,67 @@ func debugInterceptor(compName string, c *config) func(processFn) processFn {
 	}
 }
 
-func metricInterceptor(compName string, c *config, logger *elog.Component) func(processFn) processFn {
-	return func(oldProcess processFn) processFn {
-		return func(cmd *cmd) error {
+func metricInterceptor(compName string, c *config, logger *elog.Component) func(ProcessFn) ProcessFn {
+	return func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
 			beg := time.Now()
 			err := oldProcess(cmd)
 			cost := time.Since(beg)
 			if err != nil {
 				if errors.Is(err, mongo.ErrNoDocuments) {
-					emetric.ClientHandleCounter.Inc(metricType, compName, cmd.name, c.keyName, "Empty")
+					emetric.ClientHandleCounter.Inc(metricType, compName, cmd.Name, c.keyName, "Empty")
 				} else {
-					emetric.ClientHandleCounter.Inc(metricType, compName, cmd.name, c.keyName, "Error")
+					emetric.ClientHandleCounter.Inc(metricType, compName, cmd.Name, c.keyName, "Error")
 				}
 			} else {
-				emetric.ClientHandleCounter.Inc(metricType, compName, cmd.name, c.keyName, "OK")
+				emetric.ClientHandleCounter.Inc(metricType, compName, cmd.Name, c.keyName, "OK")
+			}
+			emetric.ClientHandleHistogram.WithLabelValues(metricType, compName, cmd.Name, c.keyName).Observe(cost.Seconds())
+			if cmd.IterationCost > 0 {
+				CursorIterationHistogram.WithLabelValues(metricType, compName, c.keyName).Observe(cmd.IterationCost.Seconds())
+				CursorDocumentsCounter.Add(float64(cmd.ReturnedCount), metricType, compName, c.keyName)
+			}
+			if cmd.Attempt > 0 {
+				TransactionAttemptCounter.WithLabelValues(metricType, compName, c.keyName, attemptLabel(cmd.Attempt), transactionResult(err)).Inc()
 			}
-			emetric.ClientHandleHistogram.WithLabelValues(metricType, compName, cmd.name, c.keyName).Observe(cost.Seconds())
 			return err
 		}
 	}
 }
 
-func accessInterceptor(compName string, c *config, logger *elog.Component) func(processFn) processFn {
-	return func(oldProcess processFn) processFn {
-		return func(cmd *cmd) error {
+func accessInterceptor(compName string, c *config, logger *elog.Component) func(ProcessFn) ProcessFn {
+	return func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
 			beg := time.Now()
 			err := oldProcess(cmd)
 			cost := time.Since(beg)
 
 			var fields = make([]elog.Field, 0, 15)
 			fields = append(fields,
-				elog.FieldMethod(cmd.name),
+				elog.FieldMethod(cmd.Name),
 				elog.FieldCost(cost),
-				elog.FieldKey(cmd.dbName),
-				elog.String("collName", cmd.collName),
-				elog.String("cmdName", cmd.name),
+				elog.FieldKey(cmd.DbName),
+				elog.String("collName", cmd.CollName),
+				elog.String("cmdName", cmd.Name),
 			)
+			if cmd.IterationCost > 0 {
+				fields = append(fields, elog.Int64("returned", cmd.ReturnedCount), elog.Duration("iterationCost", cmd.IterationCost))
+			}
+			if cmd.Attempt > 0 {
+				fields = append(fields, elog.Int("attempt", cmd.Attempt))
+			}
+			if cmd.InTransaction {
+				fields = append(fields, elog.Any("inTransaction", true))
+			}
+			// 开启了链路，那么就记录链路id
+			if c.EnableTraceInterceptor && etrace.IsGlobalTracerRegistered() {
+				fields = append(fields, elog.FieldTid(etrace.ExtractTraceID(cmd.Ctx)))
+			}
 			if c.EnableAccessInterceptorReq {
-				fields = append(fields, elog.Any("req", cmd.req))
+				fields = append(fields, elog.Any("req", cmd.Req))
 			}
 			if c.EnableAccessInterceptorRes && err == nil {
-				fields = append(fields, elog.Any("res", cmd.res))
+				fields = append(fields, elog.Any("res", cmd.Res))
 			}
 			event := "normal"
 			isSlowLog := false
@@ -121,7 +144,7 @@ func accessInterceptor(compName string, c *config, logger *elog.Component) func(
 				}
 				// 如果用户没开启req，那么错误必记录Req
 				if !c.EnableAccessInterceptorReq {
-					fields = append(fields, elog.Any("req", cmd.req))
+					fields = append(fields, elog.Any("req", cmd.Req))
 				}
 				logger.Error("access", fields...)
 				return err
@@ -145,14 +168,17 @@ func mustJsonMarshal(val interface{}) string {
 	return string(res)
 }
 
+// fileWithLineNum 返回调用emongo的业务代码位置，跳过emongo包中除了单测以外的所有调用，例如拦截器、Transaction
 func fileWithLineNum() string {
+	_, self, _, _ := runtime.Caller(0)
+	pkgDir := filepath.Dir(self)
 	// the second caller usually from internal, so set i start from 2
-	for i := 2; i < 15; i++ {
+	for i := 2; i < 30; i++ {
 		_, file, line, ok := runtime.Caller(i)
 		if !ok {
 			break
 		}
-		if (!(strings.Contains(file, "ego-component/emongo") && strings.HasSuffix(file, "wrapped_client.go")) && !(strings.Contains(file, "ego-component/emongo") && strings.Contains(file, "wrapped_collection.go"))) || strings.HasSuffix(file, "_test.go") {
+		if filepath.Dir(file) != pkgDir || strings.HasSuffix(file, "_test.go") {
 			return file + ":" + strconv.FormatInt(int64(line), 10)
 		}
 	}
@@ -0,0 +1,190 @@
+package emongo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half_open"
+)
+
+var breakerStates = []string{breakerClosed, breakerOpen, breakerHalfOpen}
+
+// breakerNow 熔断统计使用的时钟，单测中替换为手动推进的时钟
+var breakerNow = time.Now
+
+// exemptCmds 不访问服务端或者用于释放资源的操作，熔断、限流时依然放行
+var exemptCmds = map[string]struct{}{
+	"Database":             {},
+	"Clone":                {},
+	"WriteConcern":         {},
+	"ClusterTime":          {},
+	"OperationTime":        {},
+	"AdvanceClusterTime":   {},
+	"AdvanceOperationTime": {},
+	"StartSession":         {},
+	"EndSession":           {},
+	"StartTransaction":     {},
+	"AbortTransaction":     {},
+	"Close":                {},
+	"CursorNotClosed":      {},
+	"Connect":              {},
+	"Disconnect":           {},
+	// 事务、session回调中的每个请求已经单独统计，回调期间占用并发数会和内部的请求互相等待
+	"Transaction":           {},
+	"WithTransaction":       {},
+	"UseSession":            {},
+	"UseSessionWithOptions": {},
+}
+
+// circuitBreaker 在固定的时间窗口内统计失败率，超过阈值后熔断，OpenTimeout之后放行少量探测请求
+type circuitBreaker struct {
+	compName string
+	coll     string
+	config   *config
+
+	mu          sync.Mutex
+	state       string
+	windowStart time.Time
+	total       int
+	failures    int
+	openedAt    time.Time
+	probes      int // probes 半开状态下已经放行的探测请求
+	successes   int // successes 半开状态下成功的探测请求
+}
+
+func newCircuitBreaker(compName, coll string, config *config) *circuitBreaker {
+	b := &circuitBreaker{compName: compName, coll: coll, config: config, windowStart: breakerNow()}
+	b.setState(breakerClosed)
+	return b
+}
+
+// allow 是否放行请求，放行时返回的done需要在请求结束后调用
+func (b *circuitBreaker) allow() (done func(failure bool), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := breakerNow()
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < b.config.BreakerOpenTimeout {
+			return nil, ErrCircuitOpen
+		}
+		b.setState(breakerHalfOpen)
+		b.probes, b.successes = 0, 0
+		fallthrough
+	case breakerHalfOpen:
+		if b.probes >= b.config.BreakerHalfOpenProbes {
+			return nil, ErrCircuitOpen
+		}
+		b.probes++
+		return b.probeDone, nil
+	default:
+		if now.Sub(b.windowStart) >= b.config.BreakerWindow {
+			b.windowStart, b.total, b.failures = now, 0, 0
+		}
+		return b.done, nil
+	}
+}
+
+func (b *circuitBreaker) done(failure bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// 半开期间结束的熔断前的请求不再统计
+	if b.state != breakerClosed {
+		return
+	}
+	b.total++
+	if failure {
+		b.failures++
+	}
+	if b.total >= b.config.BreakerMinRequests && float64(b.failures) >= b.config.BreakerErrorRate*float64(b.total) {
+		b.open()
+	}
+}
+
+func (b *circuitBreaker) probeDone(failure bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != breakerHalfOpen {
+		return
+	}
+	if failure {
+		b.open()
+		return
+	}
+	b.successes++
+	if b.successes >= b.config.BreakerHalfOpenProbes {
+		b.windowStart, b.total, b.failures = breakerNow(), 0, 0
+		b.setState(breakerClosed)
+	}
+}
+
+func (b *circuitBreaker) open() {
+	b.openedAt = breakerNow()
+	b.setState(breakerOpen)
+}
+
+func (b *circuitBreaker) setState(state string) {
+	b.state = state
+	for _, s := range breakerStates {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		BreakerStateGauge.WithLabelValues(metricType, b.compName, b.coll, s).Set(value)
+	}
+}
+
+// breakerInterceptor 按Component或者集合熔断，熔断期间请求直接返回ErrCircuitOpen，不再等待SocketTimeout
+// 配置热更新后重新创建拦截器，熔断状态重新统计
+func breakerInterceptor(compName string, c *config) func(ProcessFn) ProcessFn {
+	var breakers sync.Map
+	get := func(coll string) *circuitBreaker {
+		if b, ok := breakers.Load(coll); ok {
+			return b.(*circuitBreaker)
+		}
+		b, _ := breakers.LoadOrStore(coll, newCircuitBreaker(compName, coll, c))
+		return b.(*circuitBreaker)
+	}
+	return func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
+			if _, ok := exemptCmds[cmd.Name]; ok {
+				return oldProcess(cmd)
+			}
+			coll := ""
+			if c.BreakerPerCollection && cmd.CollName != "" {
+				coll = cmd.DbName + "." + cmd.CollName
+			}
+			done, err := get(coll).allow()
+			if err != nil {
+				return err
+			}
+			beg := breakerNow()
+			err = oldProcess(cmd)
+			slow := c.BreakerSlowThreshold > 0 && breakerNow().Sub(beg) > c.BreakerSlowThreshold
+			done(slow || isBreakerFailure(err))
+			return err
+		}
+	}
+}
+
+// isBreakerFailure 只统计集群不可用导致的错误，查询不到数据、唯一键冲突等业务错误以及调用方取消的请求不统计
+func isBreakerFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var sse topology.ServerSelectionError
+	return errors.As(err, &sse) ||
+		mongo.IsNetworkError(err) ||
+		mongo.IsTimeout(err) ||
+		hasErrorCode(err, notWritablePrimaryCodes...) ||
+		hasErrorCode(err, stateChangeCodes...)
+}
@@ -0,0 +1,116 @@
+package emongo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// fakeClock 手动推进的时钟
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// useBreakerClock 熔断使用手动推进的时钟，测试结束后恢复
+func useBreakerClock(t *testing.T) *fakeClock {
+	clock := &fakeClock{now: time.Now()}
+	breakerNow = clock.Now
+	t.Cleanup(func() { breakerNow = time.Now })
+	return clock
+}
+
+func TestBreakerInterceptor(t *testing.T) {
+	clock := useBreakerClock(t)
+	h := newInterceptorHarness(func(config *config) Interceptor {
+		return breakerInterceptor("test", config)
+	}, func(config *config) {
+		config.EnableBreakerInterceptor = true
+		config.BreakerPerCollection = true
+		config.BreakerMinRequests = 4
+		config.BreakerErrorRate = 0.5
+		config.BreakerOpenTimeout = 20 * time.Millisecond
+		config.BreakerHalfOpenProbes = 2
+		config.BreakerSlowThreshold = 50 * time.Millisecond
+	})
+
+	networkErr := mongo.CommandError{Message: "connection reset", Labels: []string{"NetworkError"}}
+	var fnErr error
+	run := func(coll, name string) error {
+		_, err := h.runErrs(h.cmd(context.Background(), coll, name), fnErr)
+		return err
+	}
+
+	// 业务错误不统计
+	fnErr = mongo.ErrNoDocuments
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, mongo.ErrNoDocuments, run("users", "FindOne"))
+	}
+	fnErr = networkErr
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, networkErr, run("users", "Find"))
+	}
+	// 失败率为50%，熔断
+	calls, err := h.runErrs(h.cmd(context.Background(), "users", "Find"), fnErr)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Zero(t, calls)
+	// 释放资源的操作和其他集合不受影响
+	assert.Equal(t, networkErr, run("users", "EndSession"))
+	fnErr = nil
+	assert.NoError(t, run("orders", "Find"))
+
+	// 半开状态下探测失败，重新熔断
+	clock.Add(30 * time.Millisecond)
+	fnErr = networkErr
+	assert.Equal(t, networkErr, run("users", "Find"))
+	assert.ErrorIs(t, run("users", "Find"), ErrCircuitOpen)
+
+	// 探测全部成功后恢复
+	clock.Add(30 * time.Millisecond)
+	fnErr = nil
+	assert.NoError(t, run("users", "Find"))
+	assert.NoError(t, run("users", "Find"))
+	assert.NoError(t, run("users", "Find"))
+}
+
+func TestCircuitBreaker_HalfOpenProbes(t *testing.T) {
+	clock := useBreakerClock(t)
+	config := DefaultConfig()
+	config.BreakerOpenTimeout = time.Millisecond
+	config.BreakerHalfOpenProbes = 1
+	b := newCircuitBreaker("test", "", config)
+	b.open()
+	assert.Equal(t, breakerOpen, b.state)
+	_, err := b.allow()
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	clock.Add(2 * time.Millisecond)
+	done, err := b.allow()
+	assert.NoError(t, err)
+	assert.Equal(t, breakerHalfOpen, b.state)
+	// 探测请求结束前只放行BreakerHalfOpenProbes个请求
+	_, err = b.allow()
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	done(false)
+	assert.Equal(t, breakerClosed, b.state)
+
+	assert.False(t, isBreakerFailure(context.Canceled))
+	assert.True(t, isBreakerFailure(context.DeadlineExceeded))
+	assert.False(t, isBreakerFailure(errors.New("E11000 duplicate key")))
+}
@@ -0,0 +1,233 @@
+package emongo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	limitPolicyWait   = "wait"
+	limitPolicyReject = "reject"
+)
+
+// LimitRule 限流规则，请求匹配的所有规则都需要通过，Coll、Cmd都为空的规则作用于整个Component
+type LimitRule struct {
+	Coll        string        `json:"coll" toml:"coll"`               // Coll 集合名称，为空时匹配所有集合以及Client、Database级别的操作
+	Cmd         string        `json:"cmd" toml:"cmd"`                 // Cmd 操作名称，例如Find、InsertMany，为空时匹配所有操作
+	QPS         float64       `json:"qps" toml:"qps"`                 // QPS 令牌桶每秒生成的令牌数，为0时不限制请求速率
+	Burst       int           `json:"burst" toml:"burst"`             // Burst 令牌桶容量，为0时为QPS向上取整
+	MaxInFlight int           `json:"maxInFlight" toml:"maxInFlight"` // MaxInFlight 同时执行的最大请求数，为0时不限制并发
+	Policy      string        `json:"policy" toml:"policy"`           // Policy 超过限制时的策略，wait等待，reject立即返回错误，默认wait
+	MaxWait     time.Duration `json:"maxWait" toml:"maxWait"`         // MaxWait wait策略下最长的等待时间，为0时只受ctx的超时时间限制
+}
+
+func (r LimitRule) match(cmd *Cmd) bool {
+	return (r.Coll == "" || r.Coll == cmd.CollName) && (r.Cmd == "" || r.Cmd == cmd.Name)
+}
+
+// key 监控中的规则名称
+func (r LimitRule) key() string {
+	coll, name := r.Coll, r.Cmd
+	if coll == "" {
+		coll = "*"
+	}
+	if name == "" {
+		name = "*"
+	}
+	return coll + "." + name
+}
+
+func (r LimitRule) validate() error {
+	switch {
+	case r.QPS < 0 || r.Burst < 0 || r.MaxInFlight < 0 || r.MaxWait < 0:
+		return fmt.Errorf("qps, burst, maxInFlight and maxWait must not be negative")
+	case r.QPS == 0 && r.MaxInFlight == 0:
+		return fmt.Errorf("qps or maxInFlight is required")
+	case r.Policy != "" && r.Policy != limitPolicyWait && r.Policy != limitPolicyReject:
+		return fmt.Errorf("unsupported policy %q, must be wait or reject", r.Policy)
+	}
+	return nil
+}
+
+// tokenBucket 令牌桶，令牌不足时预留之后生成的令牌，调用方等待到令牌生成
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(qps float64, burst int) *tokenBucket {
+	if burst <= 0 {
+		burst = int(math.Ceil(qps))
+	}
+	return &tokenBucket{rate: qps, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve 预留一个令牌，返回需要等待的时间，maxWait不小于0且需要等待的时间超过maxWait时不预留
+func (b *tokenBucket) reserve(maxWait time.Duration) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	if maxWait >= 0 && wait > maxWait {
+		return wait, false
+	}
+	b.tokens--
+	return wait, true
+}
+
+// cancel 等待过程中ctx结束，归还预留的令牌
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.burst, b.tokens+1)
+}
+
+type limiter struct {
+	rule     LimitRule
+	key      string
+	compName string
+	bucket   *tokenBucket
+	sem      chan struct{}
+}
+
+// maxWait 本次请求最长可以等待的时间，小于0时不限制
+func (l *limiter) maxWait(ctx context.Context) time.Duration {
+	if l.rule.Policy == limitPolicyReject {
+		return 0
+	}
+	maxWait := time.Duration(-1)
+	if l.rule.MaxWait > 0 {
+		maxWait = l.rule.MaxWait
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		left := time.Until(deadline)
+		if left < 0 {
+			left = 0
+		}
+		if maxWait < 0 || left < maxWait {
+			maxWait = left
+		}
+	}
+	return maxWait
+}
+
+// acquire 获取令牌以及并发数，成功时返回的release需要在请求结束后调用
+func (l *limiter) acquire(ctx context.Context) (release func(), err error) {
+	if l.bucket != nil {
+		if err = l.waitToken(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if l.sem != nil {
+		if err = l.waitSlot(ctx); err != nil {
+			return nil, err
+		}
+		return func() { <-l.sem }, nil
+	}
+	return func() {}, nil
+}
+
+func (l *limiter) waitToken(ctx context.Context) error {
+	wait, ok := l.bucket.reserve(l.maxWait(ctx))
+	if !ok {
+		LimitRejectCounter.WithLabelValues(metricType, l.compName, l.key, "rate").Inc()
+		return fmt.Errorf("%w: rule %s", ErrRateLimited, l.key)
+	}
+	if wait == 0 {
+		return nil
+	}
+	LimitWaitingGauge.WithLabelValues(metricType, l.compName, l.key, "rate").Inc()
+	defer LimitWaitingGauge.WithLabelValues(metricType, l.compName, l.key, "rate").Dec()
+	defer LimitWaitHistogram.WithLabelValues(metricType, l.compName, l.key, "rate").Observe(wait.Seconds())
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.bucket.cancel()
+		LimitRejectCounter.WithLabelValues(metricType, l.compName, l.key, "rate").Inc()
+		return fmt.Errorf("%w: rule %s: %w", ErrRateLimited, l.key, ctx.Err())
+	}
+}
+
+func (l *limiter) waitSlot(ctx context.Context) error {
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	default:
+	}
+	maxWait := l.maxWait(ctx)
+	if maxWait == 0 {
+		LimitRejectCounter.WithLabelValues(metricType, l.compName, l.key, "concurrency").Inc()
+		return fmt.Errorf("%w: rule %s", ErrConcurrencyLimited, l.key)
+	}
+	var timeout <-chan time.Time
+	if maxWait > 0 {
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	LimitWaitingGauge.WithLabelValues(metricType, l.compName, l.key, "concurrency").Inc()
+	defer LimitWaitingGauge.WithLabelValues(metricType, l.compName, l.key, "concurrency").Dec()
+	beg := time.Now()
+	defer func() {
+		LimitWaitHistogram.WithLabelValues(metricType, l.compName, l.key, "concurrency").Observe(time.Since(beg).Seconds())
+	}()
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	case <-timeout:
+		LimitRejectCounter.WithLabelValues(metricType, l.compName, l.key, "concurrency").Inc()
+		return fmt.Errorf("%w: rule %s: wait %v timeout", ErrConcurrencyLimited, l.key, maxWait)
+	case <-ctx.Done():
+		LimitRejectCounter.WithLabelValues(metricType, l.compName, l.key, "concurrency").Inc()
+		return fmt.Errorf("%w: rule %s: %w", ErrConcurrencyLimited, l.key, ctx.Err())
+	}
+}
+
+// limitInterceptor 按规则限制请求速率和并发数，超过限制时根据策略等待或者返回ErrRateLimited、ErrConcurrencyLimited
+// 配置热更新后重新创建拦截器，令牌桶和并发数重新计算
+func limitInterceptor(compName string, c *config) func(ProcessFn) ProcessFn {
+	limiters := make([]*limiter, 0, len(c.Limits))
+	for _, rule := range c.Limits {
+		l := &limiter{rule: rule, key: rule.key(), compName: compName}
+		if rule.QPS > 0 {
+			l.bucket = newTokenBucket(rule.QPS, rule.Burst)
+		}
+		if rule.MaxInFlight > 0 {
+			l.sem = make(chan struct{}, rule.MaxInFlight)
+		}
+		limiters = append(limiters, l)
+	}
+	return func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
+			if _, ok := exemptCmds[cmd.Name]; ok {
+				return oldProcess(cmd)
+			}
+			for _, l := range limiters {
+				if !l.rule.match(cmd) {
+					continue
+				}
+				release, err := l.acquire(cmd.Ctx)
+				if err != nil {
+					return err
+				}
+				defer release()
+			}
+			return oldProcess(cmd)
+		}
+	}
+}
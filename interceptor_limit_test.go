@@ -0,0 +1,118 @@
+package emongo
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func newLimitHarness(setup func(config *config)) *interceptorHarness {
+	return newInterceptorHarness(func(config *config) Interceptor {
+		return limitInterceptor("test", config)
+	}, setup)
+}
+
+func TestLimitInterceptor_Rate(t *testing.T) {
+	h := newLimitHarness(func(config *config) {
+		config.Limits = []LimitRule{
+			{Coll: "jobs", QPS: 100, Burst: 2, Policy: limitPolicyReject},
+			{Cmd: "Find", QPS: 1000, Burst: 1},
+		}
+	})
+	run := func(ctx context.Context, coll, name string) error {
+		return h.run(h.cmd(ctx, coll, name), nil)
+	}
+
+	// reject策略下超过burst立即返回错误
+	assert.NoError(t, run(context.Background(), "jobs", "InsertOne"))
+	assert.NoError(t, run(context.Background(), "jobs", "InsertOne"))
+	assert.ErrorIs(t, run(context.Background(), "jobs", "InsertOne"), ErrRateLimited)
+	// 其他集合不受影响
+	assert.NoError(t, run(context.Background(), "users", "InsertOne"))
+
+	// wait策略下等待令牌生成
+	beg := time.Now()
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, run(context.Background(), "users", "Find"))
+	}
+	assert.True(t, time.Since(beg) >= 3*time.Millisecond)
+
+	// 等待时间超过ctx的超时时间时直接返回
+	h.rebuild(func(config *config) { config.Limits = []LimitRule{{QPS: 1, Burst: 1}} })
+	assert.NoError(t, run(context.Background(), "users", "Find"))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, run(ctx, "users", "Find"), ErrRateLimited)
+	// 释放资源的操作不限流
+	assert.NoError(t, run(context.Background(), "users", "EndSession"))
+}
+
+func TestLimitInterceptor_Concurrency(t *testing.T) {
+	rule := LimitRule{Coll: "jobs", MaxInFlight: 1, MaxWait: time.Minute}
+	h := newLimitHarness(func(config *config) {
+		config.Limits = []LimitRule{rule}
+	})
+	start, finish := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = h.run(h.cmd(context.Background(), "jobs", "Find"), func(c *Cmd) error {
+			close(start)
+			<-finish
+			return nil
+		})
+	}()
+	<-start
+
+	// 等待时间不超过ctx的超时时间
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, h.run(h.cmd(ctx, "jobs", "Find"), nil), ErrConcurrencyLimited)
+
+	// 进行中的请求结束后可以继续执行
+	waited := make(chan error, 1)
+	go func() { waited <- h.run(h.cmd(context.Background(), "jobs", "Find"), nil) }()
+	waiting := LimitWaitingGauge.WithLabelValues(metricType, "test", rule.key(), "concurrency")
+	assert.Eventually(t, func() bool { return testutil.ToFloat64(waiting) == 1 }, 5*time.Second, time.Millisecond)
+	close(finish)
+	select {
+	case err := <-waited:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting request was not admitted after the slot was released")
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), h.calls.Load())
+}
+
+func TestLimitRule_Validate(t *testing.T) {
+	require.NoError(t, LimitRule{QPS: 10}.validate())
+	assert.Error(t, LimitRule{}.validate())
+	assert.Error(t, LimitRule{QPS: 10, Policy: "drop"}.validate())
+	assert.Error(t, LimitRule{MaxInFlight: -1}.validate())
+	assert.Equal(t, "*.Find", LimitRule{Cmd: "Find"}.key())
+}
+
+func TestLimitInterceptor_UseSession(t *testing.T) {
+	client, _ := newMockClient(t, bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}})
+	config := DefaultConfig()
+	config.Limits = []LimitRule{{MaxInFlight: 1}}
+	client.wrapProcessor(InterceptorChain(limitInterceptor("test", config)))
+	coll := client.Database("test").Collection("jobs")
+
+	// session回调不占用并发数，否则回调中的请求会一直等待外层释放
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := client.UseSession(ctx, func(sessCtx SessionContext) error {
+		_, err := coll.InsertOne(sessCtx, bson.M{"a": 1})
+		return err
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,147 @@
+package emongo
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/gotomicro/ego/core/elog"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// retryReads 幂等的读操作，网络错误、主从切换时都可以重试
+var retryReads = map[string]struct{}{
+	"Find":                    {},
+	"FindOne":                 {},
+	"Aggregate":               {},
+	"CountDocuments":          {},
+	"Distinct":                {},
+	"EstimatedDocumentCount":  {},
+	"ListCollections":         {},
+	"ListIndexes":             {},
+	"ListIndexSpecifications": {},
+	"ListDatabaseNames":       {},
+	"ListDatabases":           {},
+	"Ping":                    {},
+}
+
+// retryWrites 写操作，只有确定没有在服务端执行时才重试
+var retryWrites = map[string]struct{}{
+	"InsertOne":         {},
+	"InsertMany":        {},
+	"UpdateOne":         {},
+	"UpdateMany":        {},
+	"UpdateByID":        {},
+	"ReplaceOne":        {},
+	"DeleteOne":         {},
+	"DeleteMany":        {},
+	"BulkWrite":         {},
+	"FindOneAndDelete":  {},
+	"FindOneAndReplace": {},
+	"FindOneAndUpdate":  {},
+}
+
+// notWritablePrimaryCodes 请求到达的节点不是primary，服务端没有执行请求
+var notWritablePrimaryCodes = []int{
+	10107, // NotWritablePrimary
+	13435, // NotPrimaryNoSecondaryOk
+	10058, // LegacyNotPrimary
+}
+
+// stateChangeCodes 主从切换、节点关闭时中断的请求，写操作可能已经执行了一部分
+var stateChangeCodes = []int{
+	189,   // PrimarySteppedDown
+	91,    // ShutdownInProgress
+	11600, // InterruptedAtShutdown
+	11602, // InterruptedDueToReplStateChange
+}
+
+// retryInterceptor 读操作遇到网络错误、主从切换、选择节点超时时重试，写操作只在节点不是primary、选择节点超时时重试
+// 事务中的请求由事务整体重试，不单独重试；重试前按指数退避加随机抖动等待，剩余时间不够时直接返回错误
+func retryInterceptor(compName string, c *config, logger *elog.Component) func(ProcessFn) ProcessFn {
+	return func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
+			err := oldProcess(cmd)
+			for attempt := 1; attempt < c.RetryMaxAttempts && shouldRetry(cmd, err); attempt++ {
+				backoff := retryBackoff(c.RetryMinBackoff, c.RetryMaxBackoff, attempt)
+				if !sleepCtx(cmd.Ctx, backoff) {
+					break
+				}
+				RetryCounter.WithLabelValues(metricType, compName, c.keyName, cmd.Name).Inc()
+				logger.Warn("mongo retry", elog.FieldMethod(cmd.Name), elog.String("collName", cmd.CollName),
+					elog.Int("attempt", attempt+1), elog.Duration("backoff", backoff), elog.FieldErr(err))
+				// 重试时重新记录请求参数
+				cmd.Req = cmd.Req[:0]
+				err = oldProcess(cmd)
+			}
+			return err
+		}
+	}
+}
+
+// shouldRetry 请求失败后是否可以重试
+func shouldRetry(cmd *Cmd, err error) bool {
+	if err == nil || cmd.InTransaction || cmd.Attempt > 0 || cmd.Ctx.Err() != nil {
+		return false
+	}
+	_, isRead := retryReads[cmd.Name]
+	_, isWrite := retryWrites[cmd.Name]
+	// $out、$merge会写入数据
+	if cmd.Name == "Aggregate" && hasWriteStage(cmd.Filter) {
+		isRead, isWrite = false, true
+	}
+	if !isRead && !isWrite {
+		return false
+	}
+	// 没有选到节点或者节点不是primary时，请求没有在服务端执行，读写都可以重试
+	var sse topology.ServerSelectionError
+	if errors.As(err, &sse) || hasErrorCode(err, notWritablePrimaryCodes...) {
+		return true
+	}
+	return isRead && (mongo.IsNetworkError(err) || hasErrorCode(err, stateChangeCodes...))
+}
+
+func hasErrorCode(err error, codes ...int) bool {
+	var se mongo.ServerError
+	if !errors.As(err, &se) {
+		return false
+	}
+	for _, code := range codes {
+		if se.HasErrorCode(code) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryBackoff 第attempt次重试前等待的时间，在[d/2, d]之间随机，d = minBackoff * 2^(attempt-1)，不超过maxBackoff
+func retryBackoff(minBackoff, maxBackoff time.Duration, attempt int) time.Duration {
+	d := minBackoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// sleepCtx 等待d，ctx在等待结束前超时或者取消时立即返回false
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= d {
+		return false
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,83 @@
+package emongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+func TestRetryInterceptor(t *testing.T) {
+	h := newInterceptorHarness(func(config *config) Interceptor {
+		return retryInterceptor("test", config, testLogger)
+	}, func(config *config) {
+		config.EnableRetryInterceptor = true
+		config.RetryMinBackoff = time.Millisecond
+		config.RetryMaxBackoff = 2 * time.Millisecond
+	})
+	run := h.runErrs
+
+	networkErr := mongo.CommandError{Message: "connection reset", Labels: []string{"NetworkError"}}
+	notPrimaryErr := mongo.CommandError{Code: 10107, Message: "not primary"}
+	selectionErr := topology.ServerSelectionError{Wrapped: topology.ErrServerSelectionTimeout}
+
+	calls, err := run(newCmd(context.Background(), "Find"), networkErr, networkErr)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	// 最多尝试RetryMaxAttempts次，每次重试前清空上一次记录的请求
+	cmd := newCmd(context.Background(), "Find")
+	calls = 0
+	err = h.run(cmd, func(c *Cmd) error {
+		calls++
+		c.Req = append(c.Req, calls)
+		return networkErr
+	})
+	assert.Equal(t, networkErr, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []interface{}{3}, cmd.Req)
+
+	// 写操作遇到网络错误时可能已经执行，不重试
+	calls, err = run(newCmd(context.Background(), "InsertOne"), networkErr)
+	assert.Equal(t, networkErr, err)
+	assert.Equal(t, 1, calls)
+	calls, err = run(newCmd(context.Background(), "InsertOne"), notPrimaryErr, selectionErr)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	// 有$out的Aggregate按写操作处理
+	cmd = newCmd(context.Background(), "Aggregate")
+	cmd.Filter = mongo.Pipeline{{{Key: "$out", Value: "target"}}}
+	calls, _ = run(cmd, networkErr)
+	assert.Equal(t, 1, calls)
+
+	// 事务中的请求、不在列表中的操作、其他错误不重试
+	cmd = newCmd(context.Background(), "Find")
+	cmd.InTransaction = true
+	calls, _ = run(cmd, networkErr)
+	assert.Equal(t, 1, calls)
+	calls, _ = run(newCmd(context.Background(), "RunCommand"), networkErr)
+	assert.Equal(t, 1, calls)
+	calls, _ = run(newCmd(context.Background(), "Find"), errors.New("bad filter"), mongo.ErrNoDocuments)
+	assert.Equal(t, 1, calls)
+
+	// 剩余时间不够等待时直接返回
+	ctx, cancel := context.WithTimeout(context.Background(), time.Microsecond)
+	defer cancel()
+	time.Sleep(time.Millisecond)
+	calls, _ = run(newCmd(ctx, "Find"), networkErr)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		d := retryBackoff(10*time.Millisecond, 30*time.Millisecond, 1)
+		assert.True(t, d >= 5*time.Millisecond && d <= 10*time.Millisecond, d)
+		d = retryBackoff(10*time.Millisecond, 30*time.Millisecond, 3)
+		assert.True(t, d >= 15*time.Millisecond && d <= 30*time.Millisecond, d)
+	}
+}
@@ -0,0 +1,187 @@
+package emongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ctxKey struct{}
+
+// interceptorHarness 内置拦截器的测试工具，使用DefaultConfig创建拦截器，多次run经过同一个拦截器，保留拦截器的状态
+type interceptorHarness struct {
+	config      *config
+	build       func(config *config) Interceptor
+	interceptor Interceptor
+	// calls 请求真正执行的总次数
+	calls atomic.Int32
+}
+
+func newInterceptorHarness(build func(config *config) Interceptor, setup func(config *config)) *interceptorHarness {
+	h := &interceptorHarness{config: DefaultConfig(), build: build}
+	h.rebuild(setup)
+	return h
+}
+
+// rebuild 修改配置后重新创建拦截器，之前的状态被丢弃
+func (h *interceptorHarness) rebuild(setup func(config *config)) {
+	if setup != nil {
+		setup(h.config)
+	}
+	h.interceptor = h.build(h.config)
+}
+
+// cmd 创建test库中coll集合的name请求
+func (h *interceptorHarness) cmd(ctx context.Context, coll, name string) *Cmd {
+	cmd := newCmd(ctx, name)
+	cmd.DbName, cmd.CollName = "test", coll
+	return cmd
+}
+
+// run 经过拦截器执行cmd，fn为nil时请求直接成功
+func (h *interceptorHarness) run(cmd *Cmd, fn ProcessFn) error {
+	return h.interceptor(func(c *Cmd) error {
+		h.calls.Add(1)
+		if fn == nil {
+			return nil
+		}
+		return fn(c)
+	})(cmd)
+}
+
+// runErrs 经过拦截器执行cmd，第i次执行返回errs[i]，之后成功，返回本次调用中请求执行的次数
+func (h *interceptorHarness) runErrs(cmd *Cmd, errs ...error) (int, error) {
+	calls := 0
+	err := h.run(cmd, func(c *Cmd) error {
+		calls++
+		if calls <= len(errs) {
+			return errs[calls-1]
+		}
+		return nil
+	})
+	return calls, err
+}
+
+func TestInterceptorChain(t *testing.T) {
+	var order []string
+	build := func(name string) Interceptor {
+		return func(oldProcess ProcessFn) ProcessFn {
+			return func(cmd *Cmd) error {
+				order = append(order, name+".before")
+				err := oldProcess(cmd)
+				order = append(order, name+".after")
+				return err
+			}
+		}
+	}
+	fn := InterceptorChain(build("a"), build("b"))(func(cmd *Cmd) error {
+		order = append(order, "process")
+		return nil
+	})
+	assert.NoError(t, fn(newCmd(context.Background(), "Ping")))
+	assert.Equal(t, []string{"a.before", "b.before", "process", "b.after", "a.after"}, order)
+}
+
+func TestInterceptor_Cmd(t *testing.T) {
+	client, err := NewClient(options.Client())
+	assert.NoError(t, err)
+
+	var got *Cmd
+	client.wrapProcessor(InterceptorChain(func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
+			got = cmd
+			return oldProcess(cmd)
+		}
+	}))
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "tenant-1")
+	filter := bson.M{"name": "foo"}
+	update := bson.M{"$set": bson.M{"name": "bar"}}
+	coll := client.Database("test").Collection("cells")
+	// 客户端没有连接，driver会直接返回错误，但是拦截器依然能拿到完整的Cmd
+	_, err = coll.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	assert.Error(t, err)
+
+	assert.Equal(t, "UpdateOne", got.Name)
+	assert.Equal(t, "test", got.DbName)
+	assert.Equal(t, "cells", got.CollName)
+	assert.Equal(t, filter, got.Filter)
+	assert.Equal(t, update, got.Update)
+	assert.Len(t, got.Opts, 1)
+	assert.Equal(t, "tenant-1", got.Ctx.Value(ctxKey{}))
+	assert.False(t, got.StartTime.IsZero())
+}
+
+func TestContainer_Interceptors(t *testing.T) {
+	c := DefaultContainer()
+	c.name = "test"
+	c.logger = testLogger
+	h := newInterceptorHarness(func(config *config) Interceptor {
+		return InterceptorChain(c.interceptors(config)...)
+	}, func(config *config) {
+		config.DefaultTimeout = 50 * time.Millisecond
+		config.EnableLimitInterceptor = true
+		config.Limits = []LimitRule{{QPS: 1, Burst: 2}}
+		config.EnableBreakerInterceptor = true
+		config.BreakerMinRequests = 2
+		config.BreakerErrorRate = 1
+		config.EnableRetryInterceptor = true
+		config.RetryMinBackoff = time.Millisecond
+		config.RetryMaxBackoff = 2 * time.Millisecond
+	})
+	networkErr := mongo.CommandError{Message: "connection reset", Labels: []string{"NetworkError"}}
+
+	// 重试在熔断之内，一次调用重试3次只统计一次失败，熔断没有打开
+	calls, err := h.runErrs(h.cmd(context.Background(), "users", "Find"), networkErr, networkErr, networkErr)
+	assert.Equal(t, networkErr, err)
+	assert.Equal(t, 3, calls)
+
+	// 超时在重试之外，每次重试都在默认超时时间内，第二次失败后熔断打开
+	deadlines := 0
+	err = h.run(h.cmd(context.Background(), "users", "Find"), func(c *Cmd) error {
+		if _, ok := c.Ctx.Deadline(); ok {
+			deadlines++
+		}
+		return networkErr
+	})
+	assert.Equal(t, networkErr, err)
+	assert.Equal(t, 3, deadlines)
+
+	// 限流在熔断之外，令牌用完后先被限流而不是返回ErrCircuitOpen
+	// 超时在限流之外，等待令牌的时间超过默认超时时直接返回
+	beg := time.Now()
+	calls, err = h.runErrs(h.cmd(context.Background(), "users", "Find"))
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Zero(t, calls)
+	assert.Less(t, time.Since(beg), time.Second)
+}
+
+func TestFileWithLineNum(t *testing.T) {
+	client, _ := newMockClient(t, bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}})
+	config := DefaultConfig()
+	config.DefaultTimeout = time.Second
+	var callers []string
+	// 与debug拦截器一样在超时拦截器之后获取调用位置
+	client.wrapProcessor(InterceptorChain(timeoutInterceptor(config), func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
+			callers = append(callers, cmd.Name+" "+fileWithLineNum())
+			return oldProcess(cmd)
+		}
+	}))
+	coll := client.Database("test").Collection("cells")
+	_, file, line, _ := runtime.Caller(0)
+	_, _ = coll.CountDocuments(context.Background(), bson.M{})
+	_ = client.Transaction(context.Background(), func(sessCtx SessionContext) error { return errors.New("abort") })
+
+	assert.Contains(t, callers, fmt.Sprintf("CountDocuments %s:%d", file, line+1))
+	assert.Contains(t, callers, fmt.Sprintf("Transaction %s:%d", file, line+2))
+}
@@ -0,0 +1,65 @@
+package emongo
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+const (
+	timeoutGroupRead  = "read"
+	timeoutGroupWrite = "write"
+)
+
+// operationTimeout 返回cmd的默认超时时间，优先使用操作名称的配置，其次是read、write分组的配置，最后是DefaultTimeout
+// DefaultTimeout以及分组的配置只作用于读写操作，游标、session等其他操作需要按名称配置
+func operationTimeout(timeouts map[string]time.Duration, defaultTimeout time.Duration, cmd *Cmd) time.Duration {
+	if d, ok := timeouts[strings.ToLower(cmd.Name)]; ok {
+		return d
+	}
+	group := ""
+	if _, ok := retryReads[cmd.Name]; ok {
+		group = timeoutGroupRead
+	}
+	if _, ok := retryWrites[cmd.Name]; ok || (cmd.Name == "Aggregate" && hasWriteStage(cmd.Filter)) {
+		group = timeoutGroupWrite
+	}
+	if group == "" {
+		return 0
+	}
+	if d, ok := timeouts[group]; ok {
+		return d
+	}
+	return defaultTimeout
+}
+
+// timeoutInterceptor 调用方的ctx没有设置超时时间时，按操作设置默认的超时时间，避免请求一直等到SocketTimeout
+// 开启TimeoutMaxTimeMS时同时设置服务端的maxTimeMS，超时后服务端也会停止执行
+func timeoutInterceptor(c *config) func(ProcessFn) ProcessFn {
+	// 配置中心可能会把key转为小写，统一按小写匹配
+	timeouts := make(map[string]time.Duration, len(c.OperationTimeouts))
+	for name, d := range c.OperationTimeouts {
+		timeouts[strings.ToLower(name)] = d
+	}
+	return func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
+			if _, ok := cmd.Ctx.Deadline(); ok {
+				return oldProcess(cmd)
+			}
+			d := operationTimeout(timeouts, c.DefaultTimeout, cmd)
+			if d <= 0 {
+				return oldProcess(cmd)
+			}
+			ctx, cancel := context.WithTimeout(cmd.Ctx, d)
+			defer cancel()
+			if o := callOptionsFromContext(ctx); c.TimeoutMaxTimeMS && (o == nil || o.maxTime <= 0) {
+				ctx = WithMaxTime(ctx, d)
+			}
+			// 拦截器返回后恢复调用方的ctx，游标等后续操作不受默认超时时间的影响
+			callerCtx := cmd.Ctx
+			cmd.Ctx = ctx
+			defer func() { cmd.Ctx = callerCtx }()
+			return oldProcess(cmd)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package emongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestOperationTimeout(t *testing.T) {
+	timeouts := map[string]time.Duration{"findone": time.Second, "write": 2 * time.Second, "startsession": 3 * time.Second}
+	defaultTimeout := 5 * time.Second
+
+	assert.Equal(t, time.Second, operationTimeout(timeouts, defaultTimeout, newCmd(context.Background(), "FindOne")))
+	assert.Equal(t, defaultTimeout, operationTimeout(timeouts, defaultTimeout, newCmd(context.Background(), "Find")))
+	assert.Equal(t, 2*time.Second, operationTimeout(timeouts, defaultTimeout, newCmd(context.Background(), "InsertOne")))
+	// 非读写操作只按名称配置
+	assert.Equal(t, 3*time.Second, operationTimeout(timeouts, defaultTimeout, newCmd(context.Background(), "StartSession")))
+	assert.Equal(t, time.Duration(0), operationTimeout(timeouts, defaultTimeout, newCmd(context.Background(), "Next")))
+
+	// 有$merge的Aggregate按写操作处理
+	cmd := newCmd(context.Background(), "Aggregate")
+	cmd.Filter = mongo.Pipeline{{{Key: "$match", Value: bson.M{}}}}
+	assert.Equal(t, defaultTimeout, operationTimeout(timeouts, defaultTimeout, cmd))
+	cmd.Filter = mongo.Pipeline{{{Key: "$merge", Value: "target"}}}
+	assert.Equal(t, 2*time.Second, operationTimeout(timeouts, defaultTimeout, cmd))
+}
+
+func TestTimeoutInterceptor(t *testing.T) {
+	h := newInterceptorHarness(func(config *config) Interceptor {
+		return timeoutInterceptor(config)
+	}, func(config *config) {
+		config.DefaultTimeout = time.Minute
+		config.OperationTimeouts = map[string]time.Duration{"FindOne": time.Second}
+	})
+	// run 返回请求执行时ctx的剩余时间和单次请求参数
+	run := func(cmd *Cmd) (remain time.Duration, o *callOptions) {
+		_ = h.run(cmd, func(c *Cmd) error {
+			if deadline, ok := c.Ctx.Deadline(); ok {
+				remain = time.Until(deadline)
+			}
+			o = callOptionsFromContext(c.Ctx)
+			return nil
+		})
+		return
+	}
+
+	ctx := context.Background()
+	cmd := newCmd(ctx, "FindOne")
+	remain, o := run(cmd)
+	assert.True(t, remain > 0 && remain <= time.Second)
+	assert.Nil(t, o)
+	// 返回后恢复调用方的ctx
+	assert.Equal(t, ctx, cmd.Ctx)
+
+	remain, _ = run(newCmd(ctx, "Find"))
+	assert.True(t, remain > time.Second && remain <= time.Minute)
+
+	// 调用方设置了超时时间时不修改
+	callerCtx, cancel := context.WithTimeout(ctx, time.Hour)
+	defer cancel()
+	remain, _ = run(newCmd(callerCtx, "FindOne"))
+	assert.True(t, remain > time.Minute)
+
+	// 开启TimeoutMaxTimeMS时设置maxTimeMS，调用方设置的maxTime优先
+	h.rebuild(func(config *config) { config.TimeoutMaxTimeMS = true })
+	_, o = run(newCmd(ctx, "FindOne"))
+	if assert.NotNil(t, o) {
+		assert.Equal(t, time.Second, o.maxTime)
+	}
+	_, o = run(newCmd(WithMaxTime(ctx, 100*time.Millisecond), "FindOne"))
+	if assert.NotNil(t, o) {
+		assert.Equal(t, 100*time.Millisecond, o.maxTime)
+	}
+}
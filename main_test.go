@@ -0,0 +1,19 @@
+package emongo
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gotomicro/ego/core/elog"
+	"go.uber.org/zap/zapcore"
+)
+
+// testLogger 测试中使用的日志，不输出任何内容，避免在仓库中写入logs/ego.sys
+var testLogger = elog.DefaultContainer().Build(elog.WithZapCore(zapcore.NewNopCore()))
+
+func TestMain(m *testing.M) {
+	// DefaultContainer等使用框架默认的日志，测试时替换掉
+	elog.EgoLogger = testLogger
+	elog.DefaultLogger = testLogger
+	os.Exit(m.Run())
+}
@@ -0,0 +1,154 @@
+package emongo
+
+import "github.com/gotomicro/ego/core/emetric"
+
+var (
+	// CursorIterationHistogram 游标从创建到关闭的总耗时
+	CursorIterationHistogram = emetric.HistogramVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_cursor_iteration_seconds",
+		Labels:    []string{"type", "name", "peer"},
+	}.Build()
+
+	// CursorDocumentsCounter 游标返回的文档数量
+	CursorDocumentsCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_cursor_documents_total",
+		Labels:    []string{"type", "name", "peer"},
+	}.Build()
+)
+
+var (
+	// BreakerStateGauge 熔断器当前的状态，当前状态为1，其他状态为0，coll为空时表示Component级别的熔断器
+	BreakerStateGauge = emetric.GaugeVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_breaker_state",
+		Labels:    []string{"type", "name", "coll", "state"},
+	}.Build()
+)
+
+var (
+	// LimitWaitHistogram 限流等待的时间，kind为rate、concurrency
+	LimitWaitHistogram = emetric.HistogramVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_limit_wait_seconds",
+		Labels:    []string{"type", "name", "rule", "kind"},
+	}.Build()
+
+	// LimitWaitingGauge 正在等待限流的请求数
+	LimitWaitingGauge = emetric.GaugeVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_limit_waiting",
+		Labels:    []string{"type", "name", "rule", "kind"},
+	}.Build()
+
+	// LimitRejectCounter 被限流拒绝的请求数，包括等待超时的请求
+	LimitRejectCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_limit_reject_total",
+		Labels:    []string{"type", "name", "rule", "kind"},
+	}.Build()
+)
+
+var (
+	// RetryCounter 重试拦截器重试的次数
+	RetryCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_retry_total",
+		Labels:    []string{"type", "name", "peer", "method"},
+	}.Build()
+)
+
+var (
+	// TransactionAttemptCounter Client.Transaction每次尝试的结果，result为commit、transient、unknown_commit、abort
+	TransactionAttemptCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_transaction_attempt_total",
+		Labels:    []string{"type", "name", "peer", "attempt", "result"},
+	}.Build()
+)
+
+var (
+	// TLSReloadCounter TLS证书重新加载的次数，result为success、fail
+	TLSReloadCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_tls_reload_total",
+		Labels:    []string{"type", "name", "result"},
+	}.Build()
+)
+
+var (
+	// CredentialRotationCounter 账号密码轮换的次数，result为success、fail
+	CredentialRotationCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_credential_rotation_total",
+		Labels:    []string{"type", "name", "result"},
+	}.Build()
+)
+
+var (
+	// PoolEventCounter 连接池事件，event为created、closed、checked_out、checked_in、cleared
+	PoolEventCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_pool_event_total",
+		Labels:    []string{"type", "name", "address", "event"},
+	}.Build()
+
+	// PoolConnectionGauge 连接池当前的连接数，state为open、in_use
+	PoolConnectionGauge = emetric.GaugeVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_pool_connections",
+		Labels:    []string{"type", "name", "address", "state"},
+	}.Build()
+
+	// PoolCheckoutFailCounter 从连接池获取连接失败的次数
+	PoolCheckoutFailCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_pool_checkout_fail_total",
+		Labels:    []string{"type", "name", "address", "reason"},
+	}.Build()
+
+	// PoolCheckoutHistogram 从连接池获取连接的等待时间
+	PoolCheckoutHistogram = emetric.HistogramVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_pool_checkout_seconds",
+		Labels:    []string{"type", "name", "address"},
+	}.Build()
+)
+
+var (
+	// ServerHeartbeatHistogram 心跳耗时
+	ServerHeartbeatHistogram = emetric.HistogramVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_server_heartbeat_seconds",
+		Labels:    []string{"type", "name", "address"},
+	}.Build()
+
+	// ServerHeartbeatFailCounter 心跳失败的次数
+	ServerHeartbeatFailCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_server_heartbeat_fail_total",
+		Labels:    []string{"type", "name", "address"},
+	}.Build()
+
+	// ServerKindGauge 节点当前的角色，当前角色为1，其他角色为0
+	ServerKindGauge = emetric.GaugeVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_server_kind",
+		Labels:    []string{"type", "name", "address", "kind"},
+	}.Build()
+
+	// TopologyChangeCounter 拓扑变化的次数，kind为变化后的拓扑类型
+	TopologyChangeCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_topology_change_total",
+		Labels:    []string{"type", "name", "kind"},
+	}.Build()
+
+	// ReconnectCounter OnFail=lazy时后台连接的次数，result为success或者fail
+	ReconnectCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "client_mongo_reconnect_total",
+		Labels:    []string{"type", "name", "result"},
+	}.Build()
+)
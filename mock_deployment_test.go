@@ -0,0 +1,232 @@
+package emongo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/address"
+	"go.mongodb.org/mongo-driver/mongo/description"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+	"go.mongodb.org/mongo-driver/x/mongo/driver"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/wiremessage"
+)
+
+const mockAddress = address.Address("localhost:27017")
+
+// mockDeployment 不依赖mongo服务端的driver.Deployment，按顺序返回预设的响应，并记录driver发送的命令
+type mockDeployment struct {
+	mu        sync.Mutex
+	responses []bson.D
+	commands  []bson.Raw
+	updates   chan description.Topology
+	// serverKind 为空时为Standalone，driver只在发送给mongos等节点时才带上$readPreference
+	serverKind description.ServerKind
+	// disconnected driver client断开后不能再发送命令
+	disconnected bool
+}
+
+var (
+	_ driver.Deployment   = &mockDeployment{}
+	_ driver.Server       = &mockDeployment{}
+	_ driver.Connection   = &mockDeployment{}
+	_ driver.Subscriber   = &mockDeployment{}
+	_ driver.Disconnector = &mockDeployment{}
+)
+
+// newMockClient 创建使用mockDeployment的Client，responses依次作为每个命令的响应
+func newMockClient(t *testing.T, responses ...bson.D) (*Client, *mockDeployment) {
+	md := &mockDeployment{responses: responses}
+	opts := options.Client()
+	opts.Deployment = md
+	client, err := Connect(context.Background(), opts)
+	require.NoError(t, err)
+	return client, md
+}
+
+// addResponses 追加响应
+func (md *mockDeployment) addResponses(responses ...bson.D) {
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	md.responses = append(md.responses, responses...)
+}
+
+// setServerKind 修改返回的节点类型
+func (md *mockDeployment) setServerKind(kind description.ServerKind) {
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	md.serverKind = kind
+}
+
+// sent 返回driver发送的所有命令
+func (md *mockDeployment) sent() []bson.Raw {
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	return append([]bson.Raw(nil), md.commands...)
+}
+
+// waitSent 等待driver发送name命令，返回该命令
+func (md *mockDeployment) waitSent(t *testing.T, name string) bson.Raw {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		for _, cmd := range md.sent() {
+			if elem, err := cmd.IndexErr(0); err == nil && elem.Key() == name {
+				return cmd
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("command %s not sent", name)
+	return nil
+}
+
+func (md *mockDeployment) SelectServer(context.Context, description.ServerSelector) (driver.Server, error) {
+	if md.isDisconnected() {
+		return nil, errors.New("mock deployment disconnected")
+	}
+	return md, nil
+}
+
+func (md *mockDeployment) Disconnect(context.Context) error {
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	md.disconnected = true
+	return nil
+}
+
+// isDisconnected driver client是否已经断开
+func (md *mockDeployment) isDisconnected() bool {
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	return md.disconnected
+}
+
+func (md *mockDeployment) Kind() description.TopologyKind { return description.Single }
+
+func (md *mockDeployment) Connection(context.Context) (driver.Connection, error) { return md, nil }
+
+func (md *mockDeployment) MinRTT() time.Duration { return 0 }
+
+func (md *mockDeployment) Subscribe() (*driver.Subscription, error) {
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	if md.updates == nil {
+		md.updates = make(chan description.Topology, 1)
+		md.updates <- description.Topology{SessionTimeoutMinutes: 30}
+	}
+	return &driver.Subscription{Updates: md.updates}, nil
+}
+
+func (md *mockDeployment) Unsubscribe(*driver.Subscription) error { return nil }
+
+// WriteWireMessage 记录OP_MSG中的命令文档，insert的documents等文档序列作为数组加入命令文档
+func (md *mockDeployment) WriteWireMessage(_ context.Context, wm []byte) error {
+	_, _, _, _, rem, ok := wiremessage.ReadHeader(wm)
+	if !ok {
+		return errors.New("malformed wire message")
+	}
+	_, rem, _ = wiremessage.ReadMsgFlags(rem)
+	_, rem, _ = wiremessage.ReadMsgSectionType(rem)
+	doc, rem, ok := bsoncore.ReadDocument(rem)
+	if !ok {
+		return errors.New("malformed command document")
+	}
+	// driver会复用wm的内存，需要复制一份
+	idx, cmd := bsoncore.AppendDocumentStart(nil)
+	elems, _ := doc.Elements()
+	for _, elem := range elems {
+		cmd = append(cmd, elem...)
+	}
+	for len(rem) > 0 {
+		var stype wiremessage.SectionType
+		if stype, rem, ok = wiremessage.ReadMsgSectionType(rem); !ok || stype != wiremessage.DocumentSequence {
+			break
+		}
+		var identifier string
+		var docs []bsoncore.Document
+		if identifier, docs, rem, ok = wiremessage.ReadMsgSectionDocumentSequence(rem); !ok {
+			return errors.New("malformed document sequence")
+		}
+		aidx, arr := bsoncore.AppendArrayStart(nil)
+		for i, d := range docs {
+			arr = bsoncore.AppendDocumentElement(arr, strconv.Itoa(i), d)
+		}
+		arr, _ = bsoncore.AppendArrayEnd(arr, aidx)
+		cmd = bsoncore.AppendArrayElement(cmd, identifier, arr)
+	}
+	cmd, _ = bsoncore.AppendDocumentEnd(cmd, idx)
+	md.mu.Lock()
+	defer md.mu.Unlock()
+	md.commands = append(md.commands, bson.Raw(cmd))
+	return nil
+}
+
+// ReadWireMessage 返回下一个预设的响应
+func (md *mockDeployment) ReadWireMessage(_ context.Context, dst []byte) ([]byte, error) {
+	md.mu.Lock()
+	if len(md.responses) == 0 {
+		md.mu.Unlock()
+		return dst, errors.New("no responses remaining")
+	}
+	res := md.responses[0]
+	md.responses = md.responses[1:]
+	md.mu.Unlock()
+
+	resBytes, err := bson.Marshal(res)
+	if err != nil {
+		return dst, err
+	}
+	var idx int32
+	idx, dst = wiremessage.AppendHeaderStart(dst, wiremessage.NextRequestID(), 0, wiremessage.OpMsg)
+	dst = wiremessage.AppendMsgFlags(dst, 0)
+	dst = wiremessage.AppendMsgSectionType(dst, wiremessage.SingleDocument)
+	dst = append(dst, resBytes...)
+	return bsoncore.UpdateLength(dst, idx, int32(len(dst[idx:]))), nil
+}
+
+func (md *mockDeployment) Description() description.Server {
+	md.mu.Lock()
+	kind := md.serverKind
+	md.mu.Unlock()
+	if kind == 0 {
+		kind = description.Standalone
+	}
+	return description.Server{
+		Addr:                  mockAddress,
+		CanonicalAddr:         mockAddress,
+		Kind:                  kind,
+		MaxDocumentSize:       16777216,
+		MaxMessageSize:        48000000,
+		MaxBatchCount:         100000,
+		SessionTimeoutMinutes: 30,
+		WireVersion:           &description.VersionRange{Max: topology.SupportedWireVersions.Max},
+	}
+}
+
+func (md *mockDeployment) Close() error { return nil }
+
+func (md *mockDeployment) ID() string { return "mock" }
+
+func (md *mockDeployment) ServerConnectionID() *int32 { return nil }
+
+func (md *mockDeployment) Address() address.Address { return mockAddress }
+
+func (md *mockDeployment) Stale() bool { return false }
+
+// cursorResponse 返回find、aggregate、getMore的响应，id为0时表示游标已经结束
+func cursorResponse(ns string, id int64, batch string, docs ...interface{}) bson.D {
+	if docs == nil {
+		docs = []interface{}{}
+	}
+	return bson.D{
+		{Key: "ok", Value: 1},
+		{Key: "cursor", Value: bson.D{{Key: "id", Value: id}, {Key: "ns", Value: ns}, {Key: batch, Value: docs}}},
+	}
+}
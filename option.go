@@ -23,3 +23,10 @@ func WithDSN(dsn string) Option {
 		c.config.DSN = dsn
 	}
 }
+
+// WithCredentialProvider 注入账号认证信息的来源，优先于配置中的UsernameFile、PasswordFile、UsernameEnv、PasswordEnv
+func WithCredentialProvider(provider CredentialProvider) Option {
+	return func(c *Container) {
+		c.credentialProvider = provider
+	}
+}
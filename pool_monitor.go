@@ -0,0 +1,61 @@
+package emongo
+
+import (
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+type poolMonitor struct {
+	compName string
+	mu       sync.Mutex
+	// checkouts 每个地址正在等待获取连接的开始时间
+	// driver的事件没有携带等待时间，并且开始事件没有连接id，这里按先进先出近似计算等待时间
+	checkouts map[string][]time.Time
+}
+
+func newPoolMonitor(compName string) *event.PoolMonitor {
+	m := &poolMonitor{compName: compName, checkouts: make(map[string][]time.Time)}
+	return &event.PoolMonitor{Event: m.event}
+}
+
+func (m *poolMonitor) event(evt *event.PoolEvent) {
+	switch evt.Type {
+	case event.ConnectionCreated:
+		PoolEventCounter.Inc(metricType, m.compName, evt.Address, "created")
+		PoolConnectionGauge.WithLabelValues(metricType, m.compName, evt.Address, "open").Inc()
+	case event.ConnectionClosed:
+		PoolEventCounter.Inc(metricType, m.compName, evt.Address, "closed")
+		PoolConnectionGauge.WithLabelValues(metricType, m.compName, evt.Address, "open").Dec()
+	case event.GetStarted:
+		m.mu.Lock()
+		m.checkouts[evt.Address] = append(m.checkouts[evt.Address], time.Now())
+		m.mu.Unlock()
+	case event.GetSucceeded:
+		m.observeCheckout(evt.Address)
+		PoolEventCounter.Inc(metricType, m.compName, evt.Address, "checked_out")
+		PoolConnectionGauge.WithLabelValues(metricType, m.compName, evt.Address, "in_use").Inc()
+	case event.GetFailed:
+		m.observeCheckout(evt.Address)
+		PoolCheckoutFailCounter.Inc(metricType, m.compName, evt.Address, evt.Reason)
+	case event.ConnectionReturned:
+		PoolEventCounter.Inc(metricType, m.compName, evt.Address, "checked_in")
+		PoolConnectionGauge.WithLabelValues(metricType, m.compName, evt.Address, "in_use").Dec()
+	case event.PoolCleared:
+		PoolEventCounter.Inc(metricType, m.compName, evt.Address, "cleared")
+	}
+}
+
+func (m *poolMonitor) observeCheckout(address string) {
+	m.mu.Lock()
+	starts := m.checkouts[address]
+	if len(starts) == 0 {
+		m.mu.Unlock()
+		return
+	}
+	beg := starts[0]
+	m.checkouts[address] = starts[1:]
+	m.mu.Unlock()
+	PoolCheckoutHistogram.WithLabelValues(metricType, m.compName, address).Observe(time.Since(beg).Seconds())
+}
@@ -0,0 +1,67 @@
+package emongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// histogramCount 返回histogram中labels对应的观测次数
+func histogramCount(t *testing.T, vec *prometheus.HistogramVec, labels ...string) uint64 {
+	observer, err := vec.GetMetricWithLabelValues(labels...)
+	assert.NoError(t, err)
+	var m dto.Metric
+	assert.NoError(t, observer.(prometheus.Metric).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestPoolMonitor(t *testing.T) {
+	const compName, address = "mongo.pool", "localhost:27017"
+	m := &poolMonitor{compName: compName, checkouts: make(map[string][]time.Time)}
+	emit := func(typ string, id uint64) {
+		m.event(&event.PoolEvent{Type: typ, Address: address, ConnectionID: id, Reason: event.ReasonTimedOut})
+	}
+	gauge := func(state string) float64 {
+		return testutil.ToFloat64(PoolConnectionGauge.WithLabelValues(metricType, compName, address, state))
+	}
+
+	emit(event.ConnectionCreated, 1)
+	emit(event.ConnectionCreated, 2)
+	assert.Equal(t, float64(2), gauge("open"))
+
+	// 三个请求同时等待连接，两个成功、一个超时，按先进先出计算等待时间
+	emit(event.GetStarted, 0)
+	emit(event.GetStarted, 0)
+	emit(event.GetStarted, 0)
+	emit(event.GetSucceeded, 2)
+	emit(event.GetSucceeded, 1)
+	emit(event.GetFailed, 0)
+	assert.Empty(t, m.checkouts[address])
+	assert.Equal(t, uint64(3), histogramCount(t, PoolCheckoutHistogram.HistogramVec, metricType, compName, address))
+	assert.Equal(t, float64(1), testutil.ToFloat64(PoolCheckoutFailCounter.WithLabelValues(metricType, compName, address, event.ReasonTimedOut)))
+	assert.Equal(t, float64(2), gauge("in_use"))
+
+	// 归还的顺序与获取的顺序不同
+	emit(event.ConnectionReturned, 1)
+	emit(event.ConnectionReturned, 2)
+	assert.Equal(t, float64(0), gauge("in_use"))
+
+	// 开始监控之前已经在等待的请求没有开始时间，不记录等待时间
+	emit(event.GetSucceeded, 1)
+	assert.Equal(t, uint64(3), histogramCount(t, PoolCheckoutHistogram.HistogramVec, metricType, compName, address))
+
+	// 使用中的连接被关闭，归还时依然会有ConnectionReturned事件
+	emit(event.PoolCleared, 0)
+	emit(event.ConnectionClosed, 1)
+	emit(event.ConnectionReturned, 1)
+	emit(event.ConnectionClosed, 2)
+	assert.Equal(t, float64(0), gauge("open"))
+	assert.Equal(t, float64(0), gauge("in_use"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(PoolEventCounter.WithLabelValues(metricType, compName, address, "cleared")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(PoolEventCounter.WithLabelValues(metricType, compName, address, "closed")))
+}
@@ -0,0 +1,209 @@
+package emongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+type primaryKey struct{}
+
+// WithPrimary 配置了ReadDSNs时，读请求依然发送到主连接，例如写入后需要立即读到最新数据
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// readerConns 只读连接，读请求轮询使用
+type readerConns struct {
+	conns []*clientConn
+	next  uint64
+}
+
+// reader 返回读请求使用的只读连接，没有配置ReadDSNs、ctx中有session、通过WithPrimary或者WithReadPreference指定primary时返回nil
+func (wc *Client) reader(ctx context.Context) *clientConn {
+	readers := wc.readers.Load()
+	if readers == nil || len(readers.conns) == 0 {
+		return nil
+	}
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary {
+		return nil
+	}
+	// 单次请求指定读primary时发送到主连接
+	if o := callOptionsFromContext(ctx); o != nil && o.readPreference != nil && o.readPreference.Mode() == readpref.PrimaryMode {
+		return nil
+	}
+	// session属于主连接的driver client，不能在只读连接上使用
+	if mongo.SessionFromContext(ctx) != nil {
+		return nil
+	}
+	n := atomic.AddUint64(&readers.next, 1)
+	return readers.conns[n%uint64(len(readers.conns))]
+}
+
+// replaceReaders 之后的读请求使用新的只读连接，旧的连接在进行中的请求结束或者超过drainTimeout后断开
+func (wc *Client) replaceReaders(ccs []*mongo.Client, drainTimeout time.Duration) {
+	var readers *readerConns
+	if len(ccs) > 0 {
+		readers = &readerConns{conns: make([]*clientConn, 0, len(ccs))}
+		for _, cc := range ccs {
+			readers.conns = append(readers.conns, &clientConn{cc: cc})
+		}
+	}
+	old := wc.readers.Swap(readers)
+	if old != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+			defer cancel()
+			for _, conn := range old.conns {
+				_ = conn.drain(ctx)
+			}
+		}()
+	}
+	if atomic.LoadInt32(&wc.closed) == 1 {
+		for _, cc := range ccs {
+			_ = cc.Disconnect(context.Background())
+		}
+	}
+}
+
+// drainReaders 等待只读连接上进行中的请求结束或者ctx结束后断开连接
+func (wc *Client) drainReaders(ctx context.Context) error {
+	readers := wc.readers.Load()
+	if readers == nil {
+		return nil
+	}
+	var errs []error
+	for _, conn := range readers.conns {
+		errs = append(errs, conn.drain(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// readCollection 读请求使用的mongo.Collection，没有只读连接时使用主连接，并使用ctx中的读偏好、读写关注，done在请求结束后调用
+func (wc *Collection) readCollection(ctx context.Context) (coll *mongo.Collection, done func()) {
+	conn := wc.database.client.reader(ctx)
+	if conn == nil {
+		return wc.contextCollection(ctx), func() {}
+	}
+	atomic.AddInt64(&conn.inflight, 1)
+	coll = conn.cc.Database(wc.database.name, wc.database.opts...).Collection(wc.name, wc.opts...)
+	return applyCallOptions(ctx, coll), func() { atomic.AddInt64(&conn.inflight, -1) }
+}
+
+// cursorRelease 返回游标的只读连接在游标读完或者关闭时才释放，替换只读连接时等待游标结束，后续的getMore不会因为断开而失败
+// 没有创建游标时立即释放
+func cursorRelease(cur *mongo.Cursor, done func()) func() {
+	if cur == nil {
+		done()
+		return nil
+	}
+	return done
+}
+
+// hasWriteStage pipeline中是否有$out、$merge，有写入的Aggregate只能发送到主连接，无法识别的pipeline也按有写入处理
+func hasWriteStage(pipeline interface{}) bool {
+	var stages []interface{}
+	switch p := pipeline.(type) {
+	case mongo.Pipeline:
+		for _, stage := range p {
+			stages = append(stages, stage)
+		}
+	case []bson.D:
+		for _, stage := range p {
+			stages = append(stages, stage)
+		}
+	case []bson.M:
+		for _, stage := range p {
+			stages = append(stages, stage)
+		}
+	case []interface{}:
+		stages = p
+	default:
+		return true
+	}
+	for _, stage := range stages {
+		var keys []string
+		switch s := stage.(type) {
+		case bson.D:
+			for _, e := range s {
+				keys = append(keys, e.Key)
+			}
+		case bson.M:
+			for key := range s {
+				keys = append(keys, key)
+			}
+		case map[string]interface{}:
+			for key := range s {
+				keys = append(keys, key)
+			}
+		default:
+			return true
+		}
+		for _, key := range keys {
+			if key == "$out" || key == "$merge" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readerConfig 只读连接的配置，副本集名称、direct、loadBalanced只使用只读DSN中的参数
+func readerConfig(config config, dsn string) config {
+	config.DSN = dsn
+	config.ReplicaSet = ""
+	config.Direct = false
+	config.LoadBalanced = false
+	return config
+}
+
+// readerName 第i个只读连接在日志、监控中的名称
+func readerName(compName string, i int) string {
+	return fmt.Sprintf("%s.reader[%d]", compName, i)
+}
+
+// connectReaders 为每个只读DSN创建driver client，失败时断开已经创建的client
+func (c *Container) connectReaders(ctx context.Context, config config, credential *CredentialConfig) ([]*mongo.Client, error) {
+	readers := make([]*mongo.Client, 0, len(config.ReadDSNs))
+	for i, dsn := range config.ReadDSNs {
+		// 只读连接的监控单独使用name.reader[i]，不计入主连接的指标，拓扑也不影响主连接的健康检查
+		clientOpts, _, err := c.clientOptions(readerName(c.name, i), readerConfig(config, dsn), credential)
+		if err == nil {
+			// 只读DSN和配置中都没有设置读偏好时，优先读secondary
+			if clientOpts.ReadPreference == nil {
+				clientOpts.SetReadPreference(readpref.SecondaryPreferred())
+			}
+			var cc *mongo.Client
+			if cc, err = mongo.Connect(ctx, clientOpts); err == nil {
+				readers = append(readers, cc)
+				continue
+			}
+			err = &DialError{Err: fmt.Errorf("readDsns[%d]: %w", i, err)}
+		}
+		disconnectAll(readers)
+		return nil, err
+	}
+	return readers, nil
+}
+
+// pingReaders 使用只读连接的读偏好ping
+func pingReaders(ctx context.Context, readers []*mongo.Client) error {
+	for i, cc := range readers {
+		if err := cc.Ping(ctx, nil); err != nil {
+			return pingError(fmt.Errorf("readDsns[%d]: %w", i, err))
+		}
+	}
+	return nil
+}
+
+func disconnectAll(ccs []*mongo.Client) {
+	for _, cc := range ccs {
+		_ = cc.Disconnect(context.Background())
+	}
+}
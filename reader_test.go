@@ -0,0 +1,117 @@
+package emongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestCollection_ReadCollection(t *testing.T) {
+	newDriverClient := func(dsn string) *mongo.Client {
+		cc, err := mongo.NewClient(options.Client().ApplyURI(dsn))
+		require.NoError(t, err)
+		return cc
+	}
+	primary := newDriverClient("mongodb://127.0.0.1:27017")
+	client := newClient(primary)
+	coll := client.Database("test").Collection("users")
+
+	// 没有只读连接时使用主连接
+	readColl, done := coll.readCollection(context.Background())
+	done()
+	assert.Same(t, primary, readColl.Database().Client())
+
+	readers := []*mongo.Client{newDriverClient("mongodb://127.0.0.1:27018"), newDriverClient("mongodb://127.0.0.1:27019")}
+	client.replaceReaders(readers, 0)
+	used := map[*mongo.Client]bool{}
+	for i := 0; i < 4; i++ {
+		readColl, done = coll.readCollection(context.Background())
+		assert.Equal(t, "test", readColl.Database().Name())
+		assert.Equal(t, "users", readColl.Name())
+		used[readColl.Database().Client()] = true
+		done()
+	}
+	assert.Equal(t, map[*mongo.Client]bool{readers[0]: true, readers[1]: true}, used)
+
+	readColl, done = coll.readCollection(WithPrimary(context.Background()))
+	done()
+	assert.Same(t, primary, readColl.Database().Client())
+	for _, conn := range client.readers.Load().conns {
+		assert.Zero(t, conn.inflight)
+	}
+}
+
+func TestHasWriteStage(t *testing.T) {
+	assert.False(t, hasWriteStage(mongo.Pipeline{{{Key: "$match", Value: bson.M{"a": 1}}}}))
+	assert.False(t, hasWriteStage([]bson.M{{"$match": bson.M{"a": 1}}, {"$limit": 1}}))
+	assert.True(t, hasWriteStage(mongo.Pipeline{{{Key: "$match", Value: bson.M{}}}, {{Key: "$out", Value: "target"}}}))
+	assert.True(t, hasWriteStage([]interface{}{bson.M{"$merge": bson.M{"into": "target"}}}))
+	// 无法识别的pipeline发送到主连接
+	assert.True(t, hasWriteStage(`[{"$match": {}}]`))
+}
+
+func TestReaderConfig(t *testing.T) {
+	config := DefaultConfig()
+	config.DSN = "mongodb://127.0.0.1:27017/test"
+	config.ReplicaSet = "rs0"
+	config.Direct = true
+	reader := readerConfig(*config, "mongodb://127.0.0.1:27018/test")
+	assert.Equal(t, "mongodb://127.0.0.1:27018/test", reader.DSN)
+	assert.Empty(t, reader.ReplicaSet)
+	assert.False(t, reader.Direct)
+	assert.Equal(t, "rs0", config.ReplicaSet)
+
+	config.ReadDSNs = []string{"127.0.0.1:27018"}
+	assert.Contains(t, config.Validate().Error(), "invalid readDsns[0]")
+}
+
+func TestReaderMonitor(t *testing.T) {
+	c := DefaultContainer()
+	c.name = "mongo.readerMonitor"
+	c.config.EnablePoolMonitor = true
+	name := readerName(c.name, 0)
+	assert.Equal(t, "mongo.readerMonitor.reader[0]", name)
+	clientOpts, monitor, err := c.clientOptions(name, readerConfig(*c.config, "mongodb://127.0.0.1:27018/test"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, name, monitor.compName)
+
+	// 只读连接的连接池指标不计入主连接
+	clientOpts.PoolMonitor.Event(&event.PoolEvent{Type: event.ConnectionCreated, Address: "127.0.0.1:27018"})
+	gauge := func(compName string) float64 {
+		return testutil.ToFloat64(PoolConnectionGauge.WithLabelValues(metricType, compName, "127.0.0.1:27018", "open"))
+	}
+	assert.Equal(t, float64(1), gauge(name))
+	assert.Zero(t, gauge(c.name))
+}
+
+func TestClient_ReplaceReadersWithOpenCursor(t *testing.T) {
+	client, _ := newMockClient(t)
+	readerClient, reader := newMockClient(t,
+		cursorResponse("test.cells", 42, "firstBatch", bson.M{"a": 1}),
+		cursorResponse("test.cells", 0, "nextBatch", bson.M{"a": 2}),
+	)
+	client.replaceReaders([]*mongo.Client{readerClient.Client()}, 0)
+	ctx := context.Background()
+	cur, err := client.Database("test").Collection("cells").Find(ctx, bson.M{})
+	require.NoError(t, err)
+
+	// 游标没有结束时，替换后的只读连接等待游标结束后才断开
+	client.replaceReaders(nil, time.Minute)
+	assert.Never(t, reader.isDisconnected, 200*time.Millisecond, 10*time.Millisecond)
+	count := 0
+	for cur.Next(ctx) {
+		count++
+	}
+	assert.NoError(t, cur.Err())
+	assert.Equal(t, 2, count)
+	assert.Eventually(t, reader.isDisconnected, 5*time.Second, 10*time.Millisecond)
+	assert.NoError(t, cur.Close(ctx))
+}
@@ -0,0 +1,68 @@
+package emongo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gotomicro/ego/core/elog"
+)
+
+const (
+	// reconnectMinBackoff OnFail=lazy时第一次重连前的等待时间
+	reconnectMinBackoff = 500 * time.Millisecond
+	// reconnectMaxBackoff OnFail=lazy时重连的最大间隔
+	reconnectMaxBackoff = 30 * time.Second
+)
+
+// reconnector OnFail=lazy时在后台连接mongo，失败后按指数退避重试，直到连接成功或者Component停止
+type reconnector struct {
+	connect    func() error
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	name       string
+	logger     *elog.Component
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	// done 后台goroutine连接成功或者停止后关闭
+	done chan struct{}
+}
+
+func (r *reconnector) start() {
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		backoff := r.minBackoff
+		timer := time.NewTimer(backoff)
+		defer timer.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-timer.C:
+			}
+			err := r.connect()
+			if err == nil {
+				ReconnectCounter.WithLabelValues(metricType, r.name, "success").Inc()
+				r.logger.Info("mongo connected in background")
+				return
+			}
+			ReconnectCounter.WithLabelValues(metricType, r.name, "fail").Inc()
+			backoff *= 2
+			if backoff > r.maxBackoff {
+				backoff = r.maxBackoff
+			}
+			r.logger.Warn("mongo connect fail, retry later", elog.FieldErr(err), elog.Duration("backoff", backoff))
+			timer.Reset(backoff)
+		}
+	}()
+}
+
+func (r *reconnector) close() {
+	r.stopOnce.Do(func() {
+		if r.stop != nil {
+			close(r.stop)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package emongo
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconnector(t *testing.T) {
+	var calls int32
+	r := &reconnector{
+		connect: func() error {
+			if atomic.AddInt32(&calls, 1) < 3 {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+		minBackoff: time.Millisecond,
+		maxBackoff: 4 * time.Millisecond,
+		name:       "test",
+		logger:     testLogger,
+	}
+	r.start()
+	defer r.close()
+	// 连接成功后不再重试
+	waitClosed(t, r.done)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// 停止后不再重试
+	calls = 0
+	r = &reconnector{
+		connect: func() error {
+			atomic.AddInt32(&calls, 1)
+			return errors.New("connection refused")
+		},
+		minBackoff: time.Millisecond,
+		maxBackoff: time.Millisecond,
+		name:       "test",
+		logger:     testLogger,
+	}
+	r.start()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) > 0 }, time.Second, time.Millisecond)
+	r.close()
+	waitClosed(t, r.done)
+	n := atomic.LoadInt32(&calls)
+	assert.Never(t, func() bool { return atomic.LoadInt32(&calls) != n }, 50*time.Millisecond, time.Millisecond)
+}
+
+// waitClosed 等待ch关闭，超时后测试失败
+func waitClosed(t *testing.T, ch <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for channel to be closed")
+	}
+}
@@ -0,0 +1,166 @@
+package emongo
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+var (
+	watchOnce sync.Once
+	// watchers 监听配置变化的Container，值为对应的Component
+	watchers sync.Map
+)
+
+// watchConfig 监听配置变化，Component停止时通过unwatchConfig移除
+// econf不能移除OnChange注册的回调，只注册一次，由watchers分发给每个Container
+func (c *Container) watchConfig(comp *Component) {
+	watchOnce.Do(func() {
+		econf.OnChange(func(conf *econf.Configuration) {
+			watchers.Range(func(key, val interface{}) bool {
+				key.(*Container).reload(val.(*Component), conf)
+				return true
+			})
+		})
+	})
+	watchers.Store(c, comp)
+}
+
+// unwatchConfig 停止监听配置变化，并等待进行中的热更新结束，之后不会再替换driver client
+func (c *Container) unwatchConfig() {
+	watchers.Delete(c)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+}
+
+// reload 使用最新的配置热更新，只覆盖配置文件中变化的配置，Build时通过Option设置的配置保持不变
+// 拦截器开关、SlowLogThreshold、EnableAccessInterceptorReq/Res、重试、熔断、限流、默认超时和事务重试次数等配置立即生效，熔断、限流的配置没有变化时保留其状态
+// DSN、连接池、认证等连接相关的配置变化时，创建新的driver client替换旧的client，旧的client在进行中的请求结束后断开
+// Debug、ShutdownTimeout、健康检查等其余配置需要重启后生效
+func (c *Container) reload(comp *Component, conf *econf.Configuration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	client := comp.client
+	if atomic.LoadInt32(&client.closed) == 1 {
+		return
+	}
+
+	fileConfig := DefaultConfig()
+	if err := conf.UnmarshalKey(c.name, fileConfig); err != nil {
+		c.logger.Error("mongo config reload fail", elog.FieldErr(err))
+		return
+	}
+	baseConfig := c.fileConfig
+	if baseConfig == nil {
+		baseConfig = DefaultConfig()
+	}
+	// 其他配置变化时不处理
+	if reflect.DeepEqual(baseConfig, fileConfig) {
+		return
+	}
+
+	newConfig := *c.config
+	overlayConfig(&newConfig, baseConfig, fileConfig)
+	newConfig.Debug = c.config.Debug
+	if err := errors.Join(newConfig.validate()...); err != nil {
+		c.logger.Error("mongo config reload fail, invalid config", elog.FieldErr(err))
+		return
+	}
+
+	if connectionChanged(c.config, &newConfig) {
+		if dsn, err := connstring.ParseAndValidate(newConfig.DSN); err == nil {
+			newConfig.keyName = c.name + "." + dsn.Database
+			newConfig.dbName = dsn.Database
+		}
+		if err := c.replaceClient(client, &newConfig, c.credential); err != nil {
+			// 继续使用旧的连接，运行时配置依然生效，fileConfig不更新，下次配置变化时重试
+			c.logger.Error("mongo config reload fail, keep the old connection", elog.FieldErr(err))
+			oldConfig := *c.config
+			c.config = runtimeConfig(&oldConfig, &newConfig)
+			client.setInterceptor(InterceptorChain(c.interceptors(c.config)...))
+			client.transactionMaxAttempts.Store(int32(c.config.TransactionMaxAttempts))
+			comp.conf.Store(c.config)
+			return
+		}
+		c.logger.Info("mongo client replaced by config reload")
+	}
+	client.setInterceptor(InterceptorChain(c.interceptors(&newConfig)...))
+	client.transactionMaxAttempts.Store(int32(newConfig.TransactionMaxAttempts))
+	c.config = &newConfig
+	c.fileConfig = fileConfig
+	comp.conf.Store(c.config)
+	c.logger.Info("mongo config reloaded")
+}
+
+// overlayConfig 把src中与base不同的配置覆盖到dst
+func overlayConfig(dst, base, src *config) {
+	dv, bv, sv := reflect.ValueOf(dst).Elem(), reflect.ValueOf(base).Elem(), reflect.ValueOf(src).Elem()
+	for i := 0; i < dv.NumField(); i++ {
+		if !dv.Type().Field(i).IsExported() {
+			continue
+		}
+		if !reflect.DeepEqual(bv.Field(i).Interface(), sv.Field(i).Interface()) {
+			dv.Field(i).Set(sv.Field(i))
+		}
+	}
+}
+
+// connectionChanged 是否有需要重建driver client的配置变化
+func connectionChanged(oldConfig, newConfig *config) bool {
+	return oldConfig.DSN != newConfig.DSN ||
+		!reflect.DeepEqual(oldConfig.ReadDSNs, newConfig.ReadDSNs) ||
+		oldConfig.SocketTimeout != newConfig.SocketTimeout ||
+		oldConfig.MaxConnIdleTime != newConfig.MaxConnIdleTime ||
+		oldConfig.MinPoolSize != newConfig.MinPoolSize ||
+		oldConfig.MaxPoolSize != newConfig.MaxPoolSize ||
+		oldConfig.EnablePoolMonitor != newConfig.EnablePoolMonitor ||
+		oldConfig.EnableServerMonitor != newConfig.EnableServerMonitor ||
+		oldConfig.AppName != newConfig.AppName ||
+		oldConfig.ReadConcern != newConfig.ReadConcern ||
+		oldConfig.ServerSelectionTimeout != newConfig.ServerSelectionTimeout ||
+		oldConfig.HeartbeatInterval != newConfig.HeartbeatInterval ||
+		oldConfig.LocalThreshold != newConfig.LocalThreshold ||
+		oldConfig.ReplicaSet != newConfig.ReplicaSet ||
+		oldConfig.Direct != newConfig.Direct ||
+		oldConfig.LoadBalanced != newConfig.LoadBalanced ||
+		!reflect.DeepEqual(oldConfig.Compressors, newConfig.Compressors) ||
+		!reflect.DeepEqual(oldConfig.ReadPreference, newConfig.ReadPreference) ||
+		!reflect.DeepEqual(oldConfig.WriteConcern, newConfig.WriteConcern) ||
+		!reflect.DeepEqual(oldConfig.RetryReads, newConfig.RetryReads) ||
+		!reflect.DeepEqual(oldConfig.RetryWrites, newConfig.RetryWrites) ||
+		!reflect.DeepEqual(oldConfig.Authentication, newConfig.Authentication)
+}
+
+// runtimeConfig 在dst中使用src的运行时配置，连接相关的配置不变
+func runtimeConfig(dst, src *config) *config {
+	dst.EnableMetricInterceptor = src.EnableMetricInterceptor
+	dst.EnableAccessInterceptor = src.EnableAccessInterceptor
+	dst.EnableAccessInterceptorReq = src.EnableAccessInterceptorReq
+	dst.EnableAccessInterceptorRes = src.EnableAccessInterceptorRes
+	dst.EnableTraceInterceptor = src.EnableTraceInterceptor
+	dst.SlowLogThreshold = src.SlowLogThreshold
+	dst.EnableRetryInterceptor = src.EnableRetryInterceptor
+	dst.RetryMaxAttempts = src.RetryMaxAttempts
+	dst.RetryMinBackoff = src.RetryMinBackoff
+	dst.RetryMaxBackoff = src.RetryMaxBackoff
+	dst.EnableBreakerInterceptor = src.EnableBreakerInterceptor
+	dst.BreakerPerCollection = src.BreakerPerCollection
+	dst.BreakerWindow = src.BreakerWindow
+	dst.BreakerMinRequests = src.BreakerMinRequests
+	dst.BreakerErrorRate = src.BreakerErrorRate
+	dst.BreakerSlowThreshold = src.BreakerSlowThreshold
+	dst.BreakerOpenTimeout = src.BreakerOpenTimeout
+	dst.BreakerHalfOpenProbes = src.BreakerHalfOpenProbes
+	dst.EnableLimitInterceptor = src.EnableLimitInterceptor
+	dst.Limits = src.Limits
+	dst.DefaultTimeout = src.DefaultTimeout
+	dst.OperationTimeouts = src.OperationTimeouts
+	dst.TimeoutMaxTimeMS = src.TimeoutMaxTimeMS
+	dst.TransactionMaxAttempts = src.TransactionMaxAttempts
+	return dst
+}
@@ -0,0 +1,181 @@
+package emongo
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+func loadConf(t *testing.T, conf string) *econf.Configuration {
+	c := econf.New()
+	require.NoError(t, c.LoadFromReader(strings.NewReader(conf), toml.Unmarshal))
+	return c
+}
+
+// newReloadComponent 使用conf中的配置创建Container和没有连接的Component，与Load、Build的顺序一致
+func newReloadComponent(t *testing.T, conf string, opts ...Option) (*Container, *Component) {
+	container := DefaultContainer()
+	container.name = "mongo"
+	require.NoError(t, loadConf(t, conf).UnmarshalKey("mongo", container.config))
+	fileConfig := *container.config
+	container.fileConfig = &fileConfig
+	for _, opt := range opts {
+		opt(container)
+	}
+
+	client, err := NewClient(options.Client())
+	require.NoError(t, err)
+	client.wrapProcessor(InterceptorChain(container.interceptors(container.config)...))
+	comp := &Component{name: container.name, client: client, logger: container.logger}
+	comp.conf.Store(container.config)
+	return container, comp
+}
+
+func TestContainer_Reload(t *testing.T) {
+	calls := 0
+	container, comp := newReloadComponent(t, `
+[mongo]
+	dsn="mongodb://localhost:27017/test"
+	dialTimeout="200ms"
+	slowLogThreshold="600ms"
+`, WithInterceptor(func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
+			calls++
+			return oldProcess(cmd)
+		}
+	}))
+	client := comp.client
+	cc := client.Client()
+
+	// 运行时配置立即生效，不重建driver client
+	container.reload(comp, loadConf(t, `
+[mongo]
+	dsn="mongodb://localhost:27017/test"
+	dialTimeout="200ms"
+	slowLogThreshold="1s"
+	enableAccessInterceptor=true
+	transactionMaxAttempts=5
+`))
+	assert.Equal(t, time.Second, container.config.SlowLogThreshold)
+	assert.Equal(t, int32(5), client.transactionMaxAttempts.Load())
+	assert.True(t, container.config.EnableAccessInterceptor)
+	assert.Same(t, container.config, comp.config())
+	assert.Same(t, cc, client.Client())
+	_ = client.Ping(context.Background(), readpref.Primary())
+	assert.Equal(t, 1, calls)
+
+	// 新的DSN连接失败时继续使用旧的连接
+	container.reload(comp, loadConf(t, `
+[mongo]
+	dsn="mongodb://127.0.0.1:1/test"
+	dialTimeout="200ms"
+	slowLogThreshold="2s"
+`))
+	assert.Equal(t, "mongodb://localhost:27017/test", container.config.DSN)
+	assert.Equal(t, 2*time.Second, comp.config().SlowLogThreshold)
+	assert.Same(t, cc, client.Client())
+	_ = client.Ping(context.Background(), readpref.Primary())
+	assert.Equal(t, 2, calls)
+}
+
+func TestContainer_ReloadKeepOptions(t *testing.T) {
+	// 配置文件中没有DSN，由WithDSN设置
+	container, comp := newReloadComponent(t, `
+[mongo]
+	slowLogThreshold="600ms"
+[other]
+	key="a"
+`, WithDSN("mongodb://localhost:27017/test"))
+	config := container.config
+
+	// 其他配置变化时不处理
+	container.reload(comp, loadConf(t, `
+[mongo]
+	slowLogThreshold="600ms"
+[other]
+	key="b"
+`))
+	assert.Same(t, config, container.config)
+
+	// 只覆盖配置文件中变化的配置，Option设置的DSN保持不变
+	container.reload(comp, loadConf(t, `
+[mongo]
+	slowLogThreshold="1s"
+`))
+	assert.Equal(t, "mongodb://localhost:27017/test", container.config.DSN)
+	assert.Equal(t, time.Second, container.config.SlowLogThreshold)
+	assert.Same(t, container.config, comp.config())
+}
+
+func TestContainer_ReloadKeepStatefulInterceptors(t *testing.T) {
+	container, comp := newReloadComponent(t, `
+[mongo]
+	dsn="mongodb://localhost:27017/test"
+	enableBreakerInterceptor=true
+	breakerMinRequests=1
+	breakerErrorRate=1
+`)
+	networkErr := mongo.CommandError{Message: "connection reset", Labels: []string{"NetworkError"}}
+	process := func(err error) error {
+		return InterceptorChain(container.interceptors(container.config)...)(func(cmd *Cmd) error {
+			return err
+		})(newCmd(context.Background(), "Find"))
+	}
+	assert.Equal(t, networkErr, process(networkErr))
+	assert.ErrorIs(t, process(nil), ErrCircuitOpen)
+
+	// 无关的配置变化不会清空熔断状态
+	container.reload(comp, loadConf(t, `
+[mongo]
+	dsn="mongodb://localhost:27017/test"
+	enableBreakerInterceptor=true
+	breakerMinRequests=1
+	breakerErrorRate=1
+	slowLogThreshold="1s"
+`))
+	assert.Equal(t, time.Second, container.config.SlowLogThreshold)
+	assert.ErrorIs(t, process(nil), ErrCircuitOpen)
+
+	// 熔断自身的配置变化时重新创建
+	container.reload(comp, loadConf(t, `
+[mongo]
+	dsn="mongodb://localhost:27017/test"
+	enableBreakerInterceptor=true
+	breakerMinRequests=2
+	breakerErrorRate=1
+	slowLogThreshold="1s"
+`))
+	assert.NoError(t, process(nil))
+}
+
+func TestContainer_UnwatchConfig(t *testing.T) {
+	container, comp := newReloadComponent(t, `
+[mongo]
+	dsn="mongodb://localhost:27017/test"
+`)
+	container.watchConfig(comp)
+	comp.unwatch = container.unwatchConfig
+	_, ok := watchers.Load(container)
+	assert.True(t, ok)
+
+	// 停止后不再监听配置变化
+	_ = comp.Close(context.Background())
+	_, ok = watchers.Load(container)
+	assert.False(t, ok)
+	config := container.config
+	container.reload(comp, loadConf(t, `
+[mongo]
+	dsn="mongodb://localhost:27017/test"
+	slowLogThreshold="1s"
+`))
+	assert.Same(t, config, container.config)
+}
@@ -0,0 +1,75 @@
+package emongo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ResumeTokenStore 保存change stream的resume token，用于进程重启或者网络异常后从上次的位置继续消费
+type ResumeTokenStore interface {
+	// Load 读取key对应的resume token，不存在时返回nil, nil
+	Load(ctx context.Context, key string) (bson.Raw, error)
+	// Save 保存key对应的resume token
+	Save(ctx context.Context, key string, token bson.Raw) error
+}
+
+type memoryResumeTokenStore struct {
+	mu     sync.RWMutex
+	tokens map[string]bson.Raw
+}
+
+// NewMemoryResumeTokenStore 返回基于内存的ResumeTokenStore，只能在网络异常时恢复，进程重启后会丢失
+func NewMemoryResumeTokenStore() ResumeTokenStore {
+	return &memoryResumeTokenStore{tokens: make(map[string]bson.Raw)}
+}
+
+func (s *memoryResumeTokenStore) Load(ctx context.Context, key string) (bson.Raw, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tokens[key], nil
+}
+
+func (s *memoryResumeTokenStore) Save(ctx context.Context, key string, token bson.Raw) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[key] = append(bson.Raw(nil), token...)
+	return nil
+}
+
+type collectionResumeTokenStore struct {
+	coll *Collection
+}
+
+// NewCollectionResumeTokenStore 返回基于mongo集合的ResumeTokenStore，每个key对应集合中_id为key的一个文档
+func NewCollectionResumeTokenStore(coll *Collection) ResumeTokenStore {
+	return &collectionResumeTokenStore{coll: coll}
+}
+
+type resumeTokenDocument struct {
+	Key       string    `bson:"_id"`
+	Token     bson.Raw  `bson:"token"`
+	UpdatedAt time.Time `bson:"updatedAt"`
+}
+
+func (s *collectionResumeTokenStore) Load(ctx context.Context, key string) (bson.Raw, error) {
+	var doc resumeTokenDocument
+	err := s.coll.FindOne(ctx, bson.M{"_id": key}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.Token, nil
+}
+
+func (s *collectionResumeTokenStore) Save(ctx context.Context, key string, token bson.Raw) error {
+	_, err := s.coll.UpdateOne(ctx, bson.M{"_id": key}, bson.M{"$set": bson.M{"token": token, "updatedAt": time.Now()}}, options.Update().SetUpsert(true))
+	return err
+}
@@ -0,0 +1,112 @@
+package emongo
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gotomicro/ego/core/elog"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/description"
+)
+
+// serverMonitor 记录driver client最新的拓扑，用于健康检查
+// 开启EnableServerMonitor时同时记录节点、拓扑变化以及心跳的监控和日志
+type serverMonitor struct {
+	compName string
+	logger   *elog.Component
+	report   bool
+	topology atomic.Pointer[description.Topology]
+}
+
+func newServerMonitor(compName string, logger *elog.Component, report bool) *serverMonitor {
+	return &serverMonitor{compName: compName, logger: logger, report: report}
+}
+
+func (m *serverMonitor) eventMonitor() *event.ServerMonitor {
+	if !m.report {
+		return &event.ServerMonitor{TopologyDescriptionChanged: m.topologyDescriptionChanged}
+	}
+	return &event.ServerMonitor{
+		ServerDescriptionChanged:   m.serverDescriptionChanged,
+		TopologyDescriptionChanged: m.topologyDescriptionChanged,
+		ServerHeartbeatSucceeded:   m.heartbeatSucceeded,
+		ServerHeartbeatFailed:      m.heartbeatFailed,
+	}
+}
+
+// hasSecondary 最新的拓扑中是否有driver心跳成功的secondary，单机部署时为false
+func (m *serverMonitor) hasSecondary() bool {
+	topology := m.topology.Load()
+	if topology == nil {
+		return false
+	}
+	for _, server := range topology.Servers {
+		if server.Kind == description.RSSecondary {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *serverMonitor) serverDescriptionChanged(evt *event.ServerDescriptionChangedEvent) {
+	prev, curr := evt.PreviousDescription.Kind, evt.NewDescription.Kind
+	if prev == curr {
+		return
+	}
+	address := evt.Address.String()
+	ServerKindGauge.Set(0, metricType, m.compName, address, prev.String())
+	ServerKindGauge.Set(1, metricType, m.compName, address, curr.String())
+	fields := []elog.Field{
+		elog.FieldAddr(address),
+		elog.String("previous", prev.String()),
+		elog.String("current", curr.String()),
+	}
+	if evt.NewDescription.LastError != nil {
+		fields = append(fields, elog.FieldErr(evt.NewDescription.LastError))
+		m.logger.Warn("mongo server description changed", fields...)
+		return
+	}
+	m.logger.Info("mongo server description changed", fields...)
+}
+
+func (m *serverMonitor) topologyDescriptionChanged(evt *event.TopologyDescriptionChangedEvent) {
+	topology := evt.NewDescription
+	m.topology.Store(&topology)
+	prev, curr := evt.PreviousDescription.Kind, evt.NewDescription.Kind
+	if !m.report || prev == curr {
+		return
+	}
+	TopologyChangeCounter.Inc(metricType, m.compName, curr.String())
+	servers := make([]string, 0, len(evt.NewDescription.Servers))
+	for _, server := range evt.NewDescription.Servers {
+		servers = append(servers, server.Addr.String()+"("+server.Kind.String()+")")
+	}
+	m.logger.Info("mongo topology changed",
+		elog.String("previous", prev.String()),
+		elog.String("current", curr.String()),
+		elog.Any("servers", servers),
+	)
+}
+
+// heartbeatSucceeded 4.4及以上版本使用streaming协议，awaited的心跳会在服务端等待heartbeatFrequency，耗时不是网络延迟，不记录
+func (m *serverMonitor) heartbeatSucceeded(evt *event.ServerHeartbeatSucceededEvent) {
+	if evt.Awaited {
+		return
+	}
+	ServerHeartbeatHistogram.WithLabelValues(metricType, m.compName, heartbeatAddress(evt.ConnectionID)).Observe(time.Duration(evt.DurationNanos).Seconds())
+}
+
+func (m *serverMonitor) heartbeatFailed(evt *event.ServerHeartbeatFailedEvent) {
+	address := heartbeatAddress(evt.ConnectionID)
+	ServerHeartbeatFailCounter.Inc(metricType, m.compName, address)
+	m.logger.Warn("mongo server heartbeat fail", elog.FieldAddr(address), elog.FieldCost(time.Duration(evt.DurationNanos)), elog.FieldErr(evt.Failure))
+}
+
+// heartbeatAddress 心跳事件的ConnectionID格式为 host:port[-N]，去掉连接序号避免指标基数过大
+func heartbeatAddress(connectionID string) string {
+	if i := strings.Index(connectionID, "[-"); i >= 0 {
+		return connectionID[:i]
+	}
+	return connectionID
+}
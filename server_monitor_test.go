@@ -0,0 +1,65 @@
+package emongo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/address"
+	"go.mongodb.org/mongo-driver/mongo/description"
+)
+
+func TestServerMonitor(t *testing.T) {
+	const compName, addr = "mongo.server", "localhost:27017"
+	monitor := newServerMonitor(compName, testLogger, true).eventMonitor()
+	heartbeats := func() uint64 {
+		return histogramCount(t, ServerHeartbeatHistogram.HistogramVec, metricType, compName, addr)
+	}
+
+	// awaited的心跳包含服务端等待的时间，不记录到心跳耗时中
+	monitor.ServerHeartbeatSucceeded(&event.ServerHeartbeatSucceededEvent{DurationNanos: 1e6, ConnectionID: addr + "[-1]"})
+	monitor.ServerHeartbeatSucceeded(&event.ServerHeartbeatSucceededEvent{DurationNanos: 1e10, ConnectionID: addr + "[-1]", Awaited: true})
+	assert.Equal(t, uint64(1), heartbeats())
+
+	monitor.ServerHeartbeatFailed(&event.ServerHeartbeatFailedEvent{ConnectionID: addr + "[-2]", Failure: errors.New("timeout")})
+	assert.Equal(t, float64(1), testutil.ToFloat64(ServerHeartbeatFailCounter.WithLabelValues(metricType, compName, addr)))
+
+	kind := func(kind description.ServerKind) float64 {
+		return testutil.ToFloat64(ServerKindGauge.WithLabelValues(metricType, compName, addr, kind.String()))
+	}
+	monitor.ServerDescriptionChanged(&event.ServerDescriptionChangedEvent{
+		Address:             address.Address(addr),
+		PreviousDescription: description.Server{Kind: description.RSSecondary},
+		NewDescription:      description.Server{Kind: description.RSPrimary},
+	})
+	assert.Equal(t, float64(0), kind(description.RSSecondary))
+	assert.Equal(t, float64(1), kind(description.RSPrimary))
+
+	// 拓扑类型不变时不记录
+	topology := func(prev, curr description.TopologyKind) {
+		monitor.TopologyDescriptionChanged(&event.TopologyDescriptionChangedEvent{
+			PreviousDescription: description.Topology{Kind: prev},
+			NewDescription:      description.Topology{Kind: curr},
+		})
+	}
+	topology(description.ReplicaSetNoPrimary, description.ReplicaSetWithPrimary)
+	topology(description.ReplicaSetWithPrimary, description.ReplicaSetWithPrimary)
+	assert.Equal(t, float64(1), testutil.ToFloat64(TopologyChangeCounter.WithLabelValues(metricType, compName, description.ReplicaSetWithPrimary.String())))
+}
+
+func TestServerMonitor_Config(t *testing.T) {
+	// 默认开启，关闭后只记录拓扑用于健康检查
+	assert.True(t, DefaultConfig().EnableServerMonitor)
+	assert.NotNil(t, newServerMonitor("test", testLogger, true).eventMonitor().ServerHeartbeatSucceeded)
+	monitor := newServerMonitor("test", testLogger, false).eventMonitor()
+	assert.Nil(t, monitor.ServerHeartbeatSucceeded)
+	assert.Nil(t, monitor.ServerDescriptionChanged)
+	assert.NotNil(t, monitor.TopologyDescriptionChanged)
+}
+
+func TestHeartbeatAddress(t *testing.T) {
+	assert.Equal(t, "localhost:27017", heartbeatAddress("localhost:27017[-3]"))
+	assert.Equal(t, "localhost:27017", heartbeatAddress("localhost:27017"))
+}
@@ -0,0 +1,125 @@
+package emongo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver"
+)
+
+// defaultTransactionMaxAttempts 没有配置TransactionMaxAttempts时事务最多尝试的次数
+const defaultTransactionMaxAttempts = 3
+
+type transactionKey struct{}
+
+// InTransaction ctx是否在事务中，例如Client.Transaction、Session.WithTransaction的回调中
+func InTransaction(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	if in, _ := ctx.Value(transactionKey{}).(bool); in {
+		return true
+	}
+	sess := mongo.SessionFromContext(ctx)
+	if ws, ok := sess.(*session); ok {
+		sess = ws.Session
+	}
+	xs, ok := sess.(mongo.XSession)
+	return ok && xs.ClientSession() != nil && xs.ClientSession().TransactionRunning()
+}
+
+// Transaction 在新的session中执行事务，fn中使用sessCtx的请求属于同一个事务，fn返回错误时回滚
+// 遇到TransientTransactionError时重新执行整个事务，提交遇到UnknownTransactionCommitResult时重新提交，都最多尝试TransactionMaxAttempts次
+// 每次尝试作为一个Transaction请求经过拦截器，Cmd.Attempt为第几次尝试，fn可能被执行多次，需要保证幂等
+// ctx已经在事务中时，直接使用外层的事务执行fn，由外层的Transaction负责提交和重试
+func (wc *Client) Transaction(ctx context.Context, fn func(sessCtx SessionContext) error, opts ...*options.TransactionOptions) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// ctx可能是从SessionContext派生的，例如context.WithTimeout(sessCtx, ...)，需要从ctx中取出session
+	if InTransaction(ctx) {
+		if sess := mongo.SessionFromContext(ctx); sess != nil {
+			return fn(mongo.NewSessionContext(ctx, sess))
+		}
+	}
+	maxAttempts := int(wc.transactionMaxAttempts.Load())
+	if maxAttempts <= 0 {
+		maxAttempts = defaultTransactionMaxAttempts
+	}
+
+	ss, err := wc.StartSession()
+	if err != nil {
+		return err
+	}
+	defer ss.EndSession(context.Background())
+	// 每次尝试已经作为一个请求记录，事务内部的Start、Commit、Abort不再单独经过拦截器
+	sess := ss.(*session).Session
+
+	for attempt := 1; ; attempt++ {
+		cmd := newCmd(ctx, "Transaction")
+		cmd.Opts = opts
+		cmd.Attempt = attempt
+		err = wc.processor(cmd, func(c *Cmd) error {
+			logCmd(wc.logMode, c, nil)
+			return runTransaction(c.Ctx, sess, fn, maxAttempts, opts)
+		})
+		if err == nil || attempt >= maxAttempts || ctx.Err() != nil || !hasErrorLabel(err, driver.TransientTransactionError) {
+			return err
+		}
+	}
+}
+
+// runTransaction 执行一次事务，提交结果未知时重新提交
+func runTransaction(ctx context.Context, sess mongo.Session, fn func(sessCtx SessionContext) error, maxAttempts int,
+	opts []*options.TransactionOptions) error {
+
+	if err := sess.StartTransaction(opts...); err != nil {
+		return err
+	}
+	sessCtx := mongo.NewSessionContext(context.WithValue(ctx, transactionKey{}, true), sess)
+	if err := fn(sessCtx); err != nil {
+		// 回滚不受ctx取消的影响，保证服务端及时释放事务占用的资源
+		_ = sess.AbortTransaction(context.Background())
+		return err
+	}
+	for commit := 1; ; commit++ {
+		err := sess.CommitTransaction(ctx)
+		if err == nil || commit >= maxAttempts || ctx.Err() != nil ||
+			!hasErrorLabel(err, driver.UnknownTransactionCommitResult) || isMaxTimeMSExpired(err) {
+			return err
+		}
+	}
+}
+
+// hasErrorLabel 服务端或者driver返回的错误是否带有label
+func hasErrorLabel(err error, label string) bool {
+	var se mongo.ServerError
+	return errors.As(err, &se) && se.HasErrorLabel(label)
+}
+
+// isMaxTimeMSExpired 提交超过maxCommitTimeMS时不再重试
+func isMaxTimeMSExpired(err error) bool {
+	var ce mongo.CommandError
+	return errors.As(err, &ce) && ce.Code == 50
+}
+
+// transactionResult 事务每次尝试的结果
+func transactionResult(err error) string {
+	switch {
+	case err == nil:
+		return "commit"
+	case hasErrorLabel(err, driver.TransientTransactionError):
+		return "transient"
+	case hasErrorLabel(err, driver.UnknownTransactionCommitResult):
+		return "unknown_commit"
+	default:
+		return "abort"
+	}
+}
+
+func attemptLabel(attempt int) string {
+	return strconv.Itoa(attempt)
+}
@@ -0,0 +1,110 @@
+package emongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver"
+)
+
+func TestTransactionResult(t *testing.T) {
+	transient := mongo.CommandError{Code: 112, Labels: []string{driver.TransientTransactionError}}
+	unknown := mongo.CommandError{Code: 91, Labels: []string{driver.UnknownTransactionCommitResult}}
+	assert.Equal(t, "commit", transactionResult(nil))
+	assert.Equal(t, "transient", transactionResult(transient))
+	assert.Equal(t, "unknown_commit", transactionResult(unknown))
+	assert.Equal(t, "abort", transactionResult(errors.New("duplicate key")))
+	// 调用方包装后的错误依然可以识别
+	assert.True(t, hasErrorLabel(&wrapErr{transient}, driver.TransientTransactionError))
+	assert.True(t, isMaxTimeMSExpired(mongo.CommandError{Code: 50}))
+}
+
+type wrapErr struct{ err error }
+
+func (e *wrapErr) Error() string { return "wrap: " + e.err.Error() }
+func (e *wrapErr) Unwrap() error { return e.err }
+
+func TestClient_Transaction(t *testing.T) {
+	client := newClient(nil)
+	var cmds []*Cmd
+	client.wrapProcessor(func(next ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
+			cmds = append(cmds, cmd)
+			return next(cmd)
+		}
+	})
+	called := false
+	err := client.Transaction(context.Background(), func(sessCtx SessionContext) error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrNotConnected)
+	assert.False(t, called)
+	assert.Equal(t, "StartSession", cmds[0].Name)
+
+	// Transaction、WithTransaction回调中的ctx在事务中
+	outer := mongo.NewSessionContext(context.WithValue(context.Background(), transactionKey{}, true), nil)
+	assert.True(t, InTransaction(outer))
+	assert.True(t, newCmd(outer, "Find").InTransaction)
+	assert.False(t, InTransaction(context.Background()))
+}
+
+func TestClient_TransactionNested(t *testing.T) {
+	client, md := newMockClient(t,
+		bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}},
+		bson.D{{Key: "ok", Value: 1}},
+	)
+	var names []string
+	client.wrapProcessor(InterceptorChain(func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
+			names = append(names, cmd.Name)
+			return oldProcess(cmd)
+		}
+	}))
+	ctx := context.Background()
+	coll := client.Database("test").Collection("cells")
+
+	// 从sessCtx派生的ctx依然使用外层的事务，不会开启新的事务
+	err := client.Transaction(ctx, func(sessCtx SessionContext) error {
+		timeoutCtx, cancel := context.WithTimeout(sessCtx, time.Second)
+		defer cancel()
+		return client.Transaction(timeoutCtx, func(inner SessionContext) error {
+			assert.Same(t, mongo.SessionFromContext(sessCtx), mongo.SessionFromContext(inner))
+			_, err := coll.InsertOne(inner, bson.M{"a": 1})
+			return err
+		})
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Database", "StartSession", "Transaction", "InsertOne", "EndSession"}, names)
+	insert := md.waitSent(t, "insert")
+	assert.True(t, insert.Lookup("startTransaction").Boolean())
+	commit := md.waitSent(t, "commitTransaction")
+	assert.Equal(t, insert.Lookup("lsid"), commit.Lookup("lsid"))
+}
+
+func TestClient_TransactionMaxAttempts(t *testing.T) {
+	client, _ := newMockClient(t)
+	transient := mongo.CommandError{Code: 112, Labels: []string{driver.TransientTransactionError}}
+	run := func() int {
+		attempts := 0
+		err := client.Transaction(context.Background(), func(sessCtx SessionContext) error {
+			attempts++
+			return transient
+		})
+		assert.Equal(t, transient, err)
+		return attempts
+	}
+	assert.Equal(t, defaultTransactionMaxAttempts, run())
+
+	// 热更新修改后下次调用立即生效
+	client.transactionMaxAttempts.Store(1)
+	assert.Equal(t, 1, run())
+	client.transactionMaxAttempts.Store(5)
+	assert.Equal(t, 5, run())
+}
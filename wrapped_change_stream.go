@@ -0,0 +1,182 @@
+package emongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// changeStreamMaxResume 连续恢复change stream的最大次数，超过后Next返回false
+const changeStreamMaxResume = 3
+
+// changeStreamResumeBackoff 每次恢复前等待的时间，随恢复次数线性增长
+var changeStreamResumeBackoff = time.Second
+
+type watchFn func(ctx context.Context, opts *options.ChangeStreamOptions) (*mongo.ChangeStream, error)
+
+// ChangeStream 对mongo.ChangeStream的封装
+// 创建、恢复、关闭以及Next失败都会经过拦截器
+// 设置了ResumeTokenStore时，消费者再次调用Next前会保存上一个事件的resume token，出错或者重启后从保存的位置继续消费
+type ChangeStream struct {
+	*mongo.ChangeStream
+	processor processor
+	logMode   bool
+	origin    *Cmd
+	opts      *options.ChangeStreamOptions
+	watchFn   watchFn
+	store     ResumeTokenStore
+	key       string
+	pending   bool
+	err       error
+}
+
+func watch(processor processor, logMode bool, origin *Cmd, store ResumeTokenStore, key string,
+	opts []*options.ChangeStreamOptions, fn watchFn) (*ChangeStream, error) {
+
+	cs := &ChangeStream{
+		processor: processor,
+		logMode:   logMode,
+		origin:    origin,
+		opts:      options.MergeChangeStreamOptions(opts...),
+		watchFn:   fn,
+		store:     store,
+		key:       key,
+	}
+	if store != nil {
+		token, err := store.Load(origin.Ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if token != nil {
+			cs.resumeAfter(token)
+		}
+	}
+
+	var stream *mongo.ChangeStream
+	err := processor(origin, func(c *Cmd) (err error) {
+		stream, err = fn(c.Ctx, cs.opts)
+		logCmd(logMode, c, stream, c.Filter)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	cs.ChangeStream = stream
+	return cs, nil
+}
+
+func (cs *ChangeStream) newCmd(ctx context.Context, name string) *Cmd {
+	c := newCmd(ctx, name)
+	c.DbName = cs.origin.DbName
+	c.CollName = cs.origin.CollName
+	c.Filter = cs.origin.Filter
+	return c
+}
+
+func (cs *ChangeStream) resumeAfter(token interface{}) {
+	cs.opts.SetResumeAfter(token)
+	cs.opts.SetStartAfter(nil)
+	cs.opts.SetStartAtOperationTime(nil)
+}
+
+// Next 阻塞获取下一个事件，遇到网络错误等可以恢复的错误时会使用最新的resume token重新创建change stream
+func (cs *ChangeStream) Next(ctx context.Context) bool {
+	return cs.next(ctx, (*mongo.ChangeStream).Next)
+}
+
+// TryNext 尝试获取下一个事件，没有事件时立即返回false，遇到可以恢复的错误时会使用最新的resume token重新创建change stream
+func (cs *ChangeStream) TryNext(ctx context.Context) bool {
+	return cs.next(ctx, (*mongo.ChangeStream).TryNext)
+}
+
+func (cs *ChangeStream) next(ctx context.Context, fn func(*mongo.ChangeStream, context.Context) bool) bool {
+	if cs.err != nil {
+		return false
+	}
+	// 消费者再次调用Next，说明上一个事件已经处理完，保存它的resume token
+	// 保存失败会由store自身的拦截器记录，下次调用Next时会再次尝试
+	if cs.pending && cs.saveResumeToken(ctx) == nil {
+		cs.pending = false
+	}
+
+	for attempt := 1; ; attempt++ {
+		if fn(cs.ChangeStream, ctx) {
+			cs.pending = true
+			return true
+		}
+		err := cs.ChangeStream.Err()
+		if err == nil {
+			return false
+		}
+		_ = cs.processor(cs.newCmd(ctx, "getMore"), func(c *Cmd) error {
+			logCmd(cs.logMode, c, nil)
+			return err
+		})
+		if ctx.Err() != nil || attempt > changeStreamMaxResume || !isResumableChangeStreamError(err) {
+			return false
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(time.Duration(attempt) * changeStreamResumeBackoff):
+		}
+		if cs.err = cs.resume(ctx); cs.err != nil {
+			return false
+		}
+	}
+}
+
+// isResumableChangeStreamError driver已经对这些错误立即恢复过一次，依然失败时说明主从切换或者网络异常还没有结束，等待一段时间后再恢复
+// ChangeStreamHistoryLost、鉴权失败、pipeline错误等恢复后依然会失败，直接返回给调用方
+func isResumableChangeStreamError(err error) bool {
+	return mongo.IsNetworkError(err) || hasErrorLabel(err, "ResumableChangeStreamError")
+}
+
+func (cs *ChangeStream) resume(ctx context.Context) error {
+	if token := cs.ChangeStream.ResumeToken(); token != nil {
+		cs.resumeAfter(token)
+	}
+	_ = cs.ChangeStream.Close(ctx)
+
+	var stream *mongo.ChangeStream
+	err := cs.processor(cs.newCmd(ctx, "ResumeWatch"), func(c *Cmd) (err error) {
+		stream, err = cs.watchFn(c.Ctx, cs.opts)
+		logCmd(cs.logMode, c, stream, c.Filter)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	cs.ChangeStream = stream
+	return nil
+}
+
+func (cs *ChangeStream) saveResumeToken(ctx context.Context) error {
+	token := cs.ChangeStream.ResumeToken()
+	if cs.store == nil || token == nil {
+		return nil
+	}
+	return cs.store.Save(ctx, cs.key, token)
+}
+
+// Err 返回change stream最近一次的错误，包括恢复失败的错误
+func (cs *ChangeStream) Err() error {
+	if cs.err != nil {
+		return cs.err
+	}
+	return cs.ChangeStream.Err()
+}
+
+// Close 保存最后一个事件的resume token，并关闭change stream
+func (cs *ChangeStream) Close(ctx context.Context) error {
+	if cs.pending && cs.saveResumeToken(ctx) == nil {
+		cs.pending = false
+	}
+	return cs.processor(cs.newCmd(ctx, "Close"), func(c *Cmd) error {
+		logCmd(cs.logMode, c, nil)
+		return cs.ChangeStream.Close(c.Ctx)
+	})
+}
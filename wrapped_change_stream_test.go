@@ -0,0 +1,103 @@
+package emongo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// fakeResumeTokenStore 记录每次保存的resume token
+type fakeResumeTokenStore struct {
+	token bson.Raw
+	saved []string
+}
+
+func (s *fakeResumeTokenStore) Load(ctx context.Context, key string) (bson.Raw, error) {
+	return s.token, nil
+}
+
+func (s *fakeResumeTokenStore) Save(ctx context.Context, key string, token bson.Raw) error {
+	s.token = token
+	s.saved = append(s.saved, token.Lookup("_data").StringValue())
+	return nil
+}
+
+func changeEvent(data string) bson.D {
+	return bson.D{{Key: "_id", Value: bson.D{{Key: "_data", Value: data}}}, {Key: "operationType", Value: "insert"}}
+}
+
+func TestChangeStream_SaveResumeToken(t *testing.T) {
+	client, md := newMockClient(t, cursorResponse("test.cells", 42, "firstBatch", changeEvent("1"), changeEvent("2")))
+	token, err := bson.Marshal(bson.D{{Key: "_data", Value: "0"}})
+	assert.NoError(t, err)
+	store := &fakeResumeTokenStore{token: token}
+	ctx := context.Background()
+	cs, err := client.Database("test").Collection("cells").WatchWithStore(ctx, store, "cells", bson.A{})
+	assert.NoError(t, err)
+	// 从store中保存的位置继续消费
+	assert.Equal(t, "0", md.waitSent(t, "aggregate").Lookup("pipeline", "0", "$changeStream", "resumeAfter", "_data").StringValue())
+
+	// 消费者再次调用Next时才保存上一个事件的resume token
+	assert.True(t, cs.Next(ctx))
+	assert.Empty(t, store.saved)
+	assert.True(t, cs.Next(ctx))
+	assert.Equal(t, []string{"1"}, store.saved)
+
+	md.addResponses(bson.D{{Key: "ok", Value: 1}})
+	assert.NoError(t, cs.Close(ctx))
+	assert.Equal(t, []string{"1", "2"}, store.saved)
+}
+
+func TestChangeStream_Resume(t *testing.T) {
+	backoff := changeStreamResumeBackoff
+	changeStreamResumeBackoff = time.Millisecond
+	defer func() { changeStreamResumeBackoff = backoff }()
+
+	resumableErr := bson.D{{Key: "ok", Value: 0}, {Key: "code", Value: 43}, {Key: "errmsg", Value: "cursor not found"},
+		{Key: "errorLabels", Value: bson.A{"ResumableChangeStreamError"}}}
+	historyLostErr := bson.D{{Key: "ok", Value: 0}, {Key: "code", Value: 286}, {Key: "errmsg", Value: "history lost"}}
+
+	client, md := newMockClient(t, cursorResponse("test.cells", 42, "firstBatch", changeEvent("1")))
+	var names []string
+	client.wrapProcessor(InterceptorChain(func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
+			names = append(names, cmd.Name)
+			return oldProcess(cmd)
+		}
+	}))
+	ctx := context.Background()
+	cs, err := client.Database("test").Collection("cells").Watch(ctx, bson.A{})
+	assert.NoError(t, err)
+	assert.True(t, cs.TryNext(ctx))
+
+	// driver恢复一次依然失败后，使用最新的resume token重新创建change stream
+	md.addResponses(
+		resumableErr,                  // getMore
+		bson.D{{Key: "ok", Value: 1}}, // driver恢复前的killCursors
+		resumableErr,                  // driver恢复时的aggregate
+		cursorResponse("test.cells", 42, "firstBatch", changeEvent("2")),
+	)
+	assert.True(t, cs.TryNext(ctx))
+	assert.Equal(t, "2", cs.ResumeToken().Lookup("_data").StringValue())
+	assert.Equal(t, []string{"Database", "Watch", "getMore", "ResumeWatch"}, names)
+	aggregates := 0
+	for _, cmd := range md.sent() {
+		if cmd.Index(0).Key() == "aggregate" {
+			aggregates++
+			if aggregates == 3 {
+				assert.Equal(t, "1", cmd.Lookup("pipeline", "0", "$changeStream", "resumeAfter", "_data").StringValue())
+			}
+		}
+	}
+	assert.Equal(t, 3, aggregates)
+
+	// ChangeStreamHistoryLost等错误恢复后依然会失败，直接返回
+	names = nil
+	md.addResponses(historyLostErr)
+	assert.False(t, cs.TryNext(ctx))
+	assert.Error(t, cs.Err())
+	assert.Equal(t, []string{"getMore"}, names)
+}
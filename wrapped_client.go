@@ -15,16 +15,56 @@ package emongo
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
+	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
+// clientConn driver client以及正在使用它的请求数
+type clientConn struct {
+	cc       *mongo.Client
+	monitor  *serverMonitor // monitor 记录cc的拓扑，不是Container创建的cc为nil
+	inflight int64
+}
+
+// drain 等待进行中的请求结束或者ctx结束后断开连接
+func (conn *clientConn) drain(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&conn.inflight) > 0 {
+		select {
+		case <-ctx.Done():
+			// ctx已经结束，Disconnect会强制关闭还在使用中的连接
+			return conn.cc.Disconnect(ctx)
+		case <-ticker.C:
+		}
+	}
+	return conn.cc.Disconnect(ctx)
+}
+
 type Client struct {
-	cc        *mongo.Client
+	conn      atomic.Pointer[clientConn]
+	readers   atomic.Pointer[readerConns]
+	wrapFn    atomic.Value
 	processor processor
 	logMode   bool
+	closed    int32
+
+	// transactionMaxAttempts 热更新时修改，在每次调用Transaction时读取
+	transactionMaxAttempts atomic.Int32
+}
+
+// newClient cc为nil时表示还没有连接，请求返回ErrNotConnected，连接成功后通过replaceClient设置
+func newClient(cc *mongo.Client) *Client {
+	wc := &Client{processor: defaultProcessor}
+	if cc != nil {
+		wc.conn.Store(&clientConn{cc: cc})
+	}
+	return wc
 }
 
 func NewClient(opts ...*options.ClientOptions) (*Client, error) {
@@ -32,70 +72,132 @@ func NewClient(opts ...*options.ClientOptions) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Client{cc: client, processor: defaultProcessor}, nil
+	return newClient(client), nil
 }
 
 func (wc *Client) setLogMode(logMode bool) {
 	wc.logMode = logMode
 }
 
-func defaultProcessor(processFn processFn) error {
-	return processFn(&cmd{req: make([]interface{}, 0, 1)})
-}
-
 func Connect(ctx context.Context, opts ...*options.ClientOptions) (wc *Client, err error) {
 	cc, err := mongo.NewClient(opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	wc = &Client{cc: cc, processor: defaultProcessor}
-	err = wc.processor(func(c *cmd) error {
-		return wc.Connect(ctx)
-	})
+	wc = newClient(cc)
+	err = wc.Connect(ctx)
 	return
 }
 
-func (wc *Client) wrapProcessor(wrapFn func(processFn) processFn) {
-	wc.processor = func(fn processFn) error {
-		return wrapFn(fn)(&cmd{req: make([]interface{}, 0, 1)})
+func (wc *Client) wrapProcessor(wrapFn func(ProcessFn) ProcessFn) {
+	wc.setInterceptor(wrapFn)
+	wc.processor = func(c *Cmd, fn ProcessFn) error {
+		// 每次请求读取最新的拦截器，配置热更新后已经创建的Database、Collection也会生效
+		wrapFn := wc.wrapFn.Load().(func(ProcessFn) ProcessFn)
+		// 先计数再判断是否已经停止，保证shutdown能等到所有已经放行的请求
+		// driver client被替换时，旧的client会等到计数归零后再断开
+		conn := wc.conn.Load()
+		if conn != nil {
+			atomic.AddInt64(&conn.inflight, 1)
+			defer atomic.AddInt64(&conn.inflight, -1)
+		}
+		if atomic.LoadInt32(&wc.closed) == 1 {
+			// 依然经过拦截器，停止后的请求会体现在日志和监控里
+			return wrapFn(func(c *Cmd) error {
+				return ErrClientClosed
+			})(c)
+		}
+		if conn == nil {
+			return wrapFn(func(c *Cmd) error {
+				return ErrNotConnected
+			})(c)
+		}
+		return wrapFn(fn)(c)
+	}
+}
+
+// setInterceptor 替换拦截器，需要先调用wrapProcessor才会生效
+func (wc *Client) setInterceptor(wrapFn func(ProcessFn) ProcessFn) {
+	wc.wrapFn.Store(wrapFn)
+}
+
+// shutdown 拒绝新的请求，等待进行中的请求结束或者ctx结束后断开连接
+func (wc *Client) shutdown(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&wc.closed, 0, 1) {
+		return nil
+	}
+	var err error
+	if conn := wc.conn.Load(); conn != nil {
+		err = conn.drain(ctx)
+	}
+	return errors.Join(err, wc.drainReaders(ctx))
+}
+
+// disconnect 断开并清空所有的driver client，之后的请求返回ErrNotConnected
+func (wc *Client) disconnect(ctx context.Context) {
+	if conn := wc.conn.Swap(nil); conn != nil {
+		_ = conn.cc.Disconnect(ctx)
+	}
+	if readers := wc.readers.Swap(nil); readers != nil {
+		for _, conn := range readers.conns {
+			_ = conn.cc.Disconnect(ctx)
+		}
+	}
+}
+
+// replaceClient 之后的请求使用新的driver client，旧的client在进行中的请求结束或者超过drainTimeout后断开
+func (wc *Client) replaceClient(cc *mongo.Client, monitor *serverMonitor, drainTimeout time.Duration) {
+	old := wc.conn.Swap(&clientConn{cc: cc, monitor: monitor})
+	if old != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+			defer cancel()
+			_ = old.drain(ctx)
+		}()
+	}
+	// 替换过程中Client已经停止，shutdown可能已经断开了旧的client，新的client需要自己断开
+	if atomic.LoadInt32(&wc.closed) == 1 {
+		_ = cc.Disconnect(context.Background())
 	}
 }
 
 func (wc *Client) Connect(ctx context.Context) error {
-	return wc.processor(func(c *cmd) error {
-		logCmd(wc.logMode, c, "Connect", nil)
-		return wc.cc.Connect(ctx)
+	return wc.processor(newCmd(ctx, "Connect"), func(c *Cmd) error {
+		logCmd(wc.logMode, c, nil)
+		return wc.Client().Connect(c.Ctx)
 	})
 }
 
 func (wc *Client) Database(name string, opts ...*options.DatabaseOptions) *Database {
-	var db *mongo.Database
-	_ = wc.processor(func(c *cmd) error {
-		db = wc.cc.Database(name, opts...)
-		c.dbName = name
-		logCmd(wc.logMode, c, "Connect", db, name)
+	db := &Database{client: wc, name: name, opts: opts, processor: wc.processor, logMode: wc.logMode}
+	cmd := newCmd(context.Background(), "Database")
+	cmd.DbName = name
+	cmd.Opts = opts
+	// 拦截器拒绝时依然返回Database，后续的请求会经过拦截器返回错误
+	_ = wc.processor(cmd, func(c *Cmd) error {
+		logCmd(wc.logMode, c, db.database(), name)
 		return nil
 	})
-	if db == nil {
-		return nil
-	}
-	return &Database{db: db, processor: wc.processor, logMode: wc.logMode}
+	return db
 }
 
 func (wc *Client) Disconnect(ctx context.Context) error {
-	return wc.processor(func(c *cmd) error {
-		logCmd(wc.logMode, c, "Disconnect", nil)
-		return wc.cc.Disconnect(ctx)
+	return wc.processor(newCmd(ctx, "Disconnect"), func(c *Cmd) error {
+		logCmd(wc.logMode, c, nil)
+		return wc.Client().Disconnect(c.Ctx)
 	})
 }
 
 func (wc *Client) ListDatabaseNames(ctx context.Context, filter interface{}, opts ...*options.ListDatabasesOptions) (
 	dbs []string, err error) {
 
-	err = wc.processor(func(c *cmd) error {
-		dbs, err = wc.cc.ListDatabaseNames(ctx, filter, opts...)
-		logCmd(wc.logMode, c, "ListDatabaseNames", dbs, filter)
+	cmd := newCmd(ctx, "ListDatabaseNames")
+	cmd.Filter = filter
+	cmd.Opts = opts
+	err = wc.processor(cmd, func(c *Cmd) error {
+		dbs, err = wc.Client().ListDatabaseNames(c.Ctx, filter, opts...)
+		logCmd(wc.logMode, c, dbs, filter)
 		return err
 	})
 	return
@@ -104,41 +206,73 @@ func (wc *Client) ListDatabaseNames(ctx context.Context, filter interface{}, opt
 func (wc *Client) ListDatabases(ctx context.Context, filter interface{}, opts ...*options.ListDatabasesOptions) (
 	dbr mongo.ListDatabasesResult, err error) {
 
-	err = wc.processor(func(c *cmd) error {
-		dbr, err = wc.cc.ListDatabases(ctx, filter, opts...)
-		logCmd(wc.logMode, c, "ListDatabases", dbr, filter)
+	cmd := newCmd(ctx, "ListDatabases")
+	cmd.Filter = filter
+	cmd.Opts = opts
+	err = wc.processor(cmd, func(c *Cmd) error {
+		dbr, err = wc.Client().ListDatabases(c.Ctx, filter, opts...)
+		logCmd(wc.logMode, c, dbr, filter)
 		return err
 	})
 	return
 }
 
 func (wc *Client) Ping(ctx context.Context, rp *readpref.ReadPref) error {
-	return wc.processor(func(c *cmd) error {
-		logCmd(wc.logMode, c, "Ping", nil, rp)
-		return wc.cc.Ping(ctx, rp)
+	cmd := newCmd(ctx, "Ping")
+	cmd.Opts = rp
+	return wc.processor(cmd, func(c *Cmd) error {
+		logCmd(wc.logMode, c, nil, rp)
+		return wc.Client().Ping(c.Ctx, rp)
 	})
 }
 
 func (wc *Client) StartSession(opts ...*options.SessionOptions) (ss Session, err error) {
-	err = wc.processor(func(c *cmd) error {
-		ss, err = wc.cc.StartSession(opts...)
-		logCmd(wc.logMode, c, "StartSession", ss)
+	cmd := newCmd(context.Background(), "StartSession")
+	cmd.Opts = opts
+	err = wc.processor(cmd, func(c *Cmd) error {
+		ss, err = wc.Client().StartSession(opts...)
+		logCmd(wc.logMode, c, ss)
 		return err
 	})
+	if err != nil {
+		// 拦截器在session创建之后返回错误时，session不会再被使用
+		if ss != nil {
+			ss.EndSession(context.Background())
+		}
+		return nil, err
+	}
 	return &session{Session: ss, logMode: wc.logMode, processor: wc.processor}, nil
 }
 
 func (wc *Client) UseSession(ctx context.Context, fn func(SessionContext) error) error {
-	return wc.processor(func(c *cmd) error {
-		logCmd(wc.logMode, c, "UseSession", nil)
-		return wc.cc.UseSession(ctx, fn)
+	return wc.processor(newCmd(ctx, "UseSession"), func(c *Cmd) error {
+		logCmd(wc.logMode, c, nil)
+		return wc.Client().UseSession(c.Ctx, fn)
 	})
 }
 
 func (wc *Client) UseSessionWithOptions(ctx context.Context, opts *options.SessionOptions, fn func(SessionContext) error) error {
-	return wc.processor(func(c *cmd) error {
-		logCmd(wc.logMode, c, "UseSessionWithOptions", nil)
-		return wc.cc.UseSessionWithOptions(ctx, opts, fn)
+	cmd := newCmd(ctx, "UseSessionWithOptions")
+	cmd.Opts = opts
+	return wc.processor(cmd, func(c *Cmd) error {
+		logCmd(wc.logMode, c, nil)
+		return wc.Client().UseSessionWithOptions(c.Ctx, opts, fn)
+	})
+}
+
+func (wc *Client) Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (*ChangeStream, error) {
+	return wc.WatchWithStore(ctx, nil, "", pipeline, opts...)
+}
+
+// WatchWithStore 创建change stream，启动时从store中读取key对应的resume token继续消费，并在消费过程中保存resume token
+func (wc *Client) WatchWithStore(ctx context.Context, store ResumeTokenStore, key string, pipeline interface{},
+	opts ...*options.ChangeStreamOptions) (*ChangeStream, error) {
+
+	cmd := newCmd(ctx, "Watch")
+	cmd.Filter = pipeline
+	cmd.Opts = opts
+	return watch(wc.processor, wc.logMode, cmd, store, key, opts, func(ctx context.Context, opts *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+		return wc.Client().Watch(ctx, pipeline, opts)
 	})
 }
 
@@ -146,4 +280,11 @@ func WithSession(ctx context.Context, sess Session, fn func(SessionContext) erro
 	return mongo.WithSession(ctx, sess, fn)
 }
 
-func (wc *Client) Client() *mongo.Client { return wc.cc }
+// Client 返回当前的driver client，OnFail=lazy还没有连接成功时返回nil
+func (wc *Client) Client() *mongo.Client {
+	conn := wc.conn.Load()
+	if conn == nil {
+		return nil
+	}
+	return conn.cc
+}
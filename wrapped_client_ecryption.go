@@ -30,7 +30,11 @@ type ClientEncryption struct {
 }
 
 func (wc *Client) NewClientEncryption(opts ...*options.ClientEncryptionOptions) (*ClientEncryption, error) {
-	client, err := mongo.NewClientEncryption(wc.Client(), opts...)
+	cc := wc.Client()
+	if cc == nil {
+		return nil, ErrNotConnected
+	}
+	client, err := mongo.NewClientEncryption(cc, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -40,9 +44,9 @@ func (wc *Client) NewClientEncryption(opts ...*options.ClientEncryptionOptions)
 func (wce *ClientEncryption) CreateDataKey(ctx context.Context, kmsProvider string, opts ...*options.DataKeyOptions) (
 	id primitive.Binary, err error) {
 
-	err = wce.processor(func(c *cmd) error {
-		id, err = wce.cc.CreateDataKey(ctx, kmsProvider, opts...)
-		logCmd(wce.logMode, c, "CreateDataKey", id)
+	err = wce.processor(newCmd(ctx, "CreateDataKey"), func(c *Cmd) error {
+		id, err = wce.cc.CreateDataKey(c.Ctx, kmsProvider, opts...)
+		logCmd(wce.logMode, c, id)
 		return err
 	})
 	return
@@ -51,26 +55,26 @@ func (wce *ClientEncryption) CreateDataKey(ctx context.Context, kmsProvider stri
 func (wce *ClientEncryption) Encrypt(ctx context.Context, val bson.RawValue, opts ...*options.EncryptOptions) (
 	value primitive.Binary, err error) {
 
-	err = wce.processor(func(c *cmd) error {
-		value, err = wce.cc.Encrypt(ctx, val, opts...)
-		logCmd(wce.logMode, c, "Encrypt", value, val)
+	err = wce.processor(newCmd(ctx, "Encrypt"), func(c *Cmd) error {
+		value, err = wce.cc.Encrypt(c.Ctx, val, opts...)
+		logCmd(wce.logMode, c, value, val)
 		return err
 	})
 	return
 }
 
 func (wce *ClientEncryption) Decrypt(ctx context.Context, val primitive.Binary) (value bson.RawValue, err error) {
-	err = wce.processor(func(c *cmd) error {
-		value, err = wce.cc.Decrypt(ctx, val)
-		logCmd(wce.logMode, c, "Decrypt", value, val)
+	err = wce.processor(newCmd(ctx, "Decrypt"), func(c *Cmd) error {
+		value, err = wce.cc.Decrypt(c.Ctx, val)
+		logCmd(wce.logMode, c, value, val)
 		return err
 	})
 	return
 }
 
 func (wce *ClientEncryption) Close(ctx context.Context) error {
-	return wce.processor(func(c *cmd) error {
-		logCmd(wce.logMode, c, "Close", nil)
-		return wce.cc.Close(ctx)
+	return wce.processor(newCmd(ctx, "Close"), func(c *Cmd) error {
+		logCmd(wce.logMode, c, nil)
+		return wce.cc.Close(c.Ctx)
 	})
 }
@@ -0,0 +1,91 @@
+package emongo
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestClient_Shutdown(t *testing.T) {
+	client, err := NewClient(options.Client())
+	assert.NoError(t, err)
+	started, release := make(chan struct{}), make(chan struct{})
+	client.wrapProcessor(InterceptorChain(func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
+			if cmd.Name == "CountDocuments" {
+				close(started)
+				<-release
+			}
+			return oldProcess(cmd)
+		}
+	}))
+	coll := client.Database("test").Collection("cells")
+
+	// 进行中的请求
+	done := make(chan struct{})
+	go func() {
+		_, _ = coll.CountDocuments(context.Background(), bson.M{})
+		close(done)
+	}()
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		_ = client.shutdown(context.Background())
+		close(stopped)
+	}()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&client.closed) == 1 }, 5*time.Second, time.Millisecond)
+
+	// 停止后的请求立即失败
+	_, err = coll.InsertOne(context.Background(), bson.M{"a": 1})
+	assert.ErrorIs(t, err, ErrClientClosed)
+
+	select {
+	case <-stopped:
+		t.Fatal("shutdown returned before in-flight request finished")
+	default:
+	}
+	close(release)
+	<-done
+	<-stopped
+}
+
+func TestClient_ReplaceClient(t *testing.T) {
+	client, err := NewClient(options.Client())
+	assert.NoError(t, err)
+	coll := client.Database("test").Collection("cells")
+	old := coll.Collection()
+	assert.Same(t, client.Client(), old.Database().Client())
+
+	cc, err := mongo.NewClient(options.Client())
+	assert.NoError(t, err)
+	client.replaceClient(cc, nil, time.Second)
+	// 已经创建的Collection使用新的driver client
+	assert.Same(t, cc, coll.Collection().Database().Client())
+	assert.Equal(t, "test", coll.Database().Name())
+	assert.Equal(t, "cells", coll.Name())
+}
+
+func TestClient_ReplaceClientInSession(t *testing.T) {
+	client, md := newMockClient(t, bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}})
+	coll := client.Database("test").Collection("cells")
+	newClient, newMd := newMockClient(t)
+
+	err := client.UseSession(context.Background(), func(sessCtx SessionContext) error {
+		// session回调中替换driver client，回调中的请求依然使用session所属的client
+		client.replaceClient(newClient.Client(), nil, time.Minute)
+		_, err := coll.InsertOne(sessCtx, bson.M{"a": 1})
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "cells", md.waitSent(t, "insert").Lookup("insert").StringValue())
+	assert.Empty(t, newMd.sent())
+	// session之外的请求使用新的client
+	assert.Same(t, newClient.Client(), coll.Collection().Database().Client())
+}
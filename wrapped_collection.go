@@ -16,246 +16,290 @@ package emongo
 
 import (
 	"context"
+	"sync/atomic"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type processor func(fn processFn) error
-type processFn func(*cmd) error
-
-type cmd struct {
-	name     string
-	req      []interface{}
-	res      interface{}
-	dbName   string
-	collName string
-}
-
-func logCmd(logMode bool, c *cmd, name string, res interface{}, req ...interface{}) {
-	// 只有开启log模式才会记录req、res
-	if logMode {
-		c.name = name
-		c.req = append(c.req, req...)
-		switch res := res.(type) {
-		case *mongo.SingleResult:
-			val, _ := res.DecodeBytes()
-			c.res = val
-		default:
-			c.res = res
-		}
+// singleResult processor没有执行driver时（例如拦截器直接返回错误）res为nil，返回一个带有错误的SingleResult
+func singleResult(res *mongo.SingleResult, err error) *mongo.SingleResult {
+	if res != nil {
+		return res
 	}
+	return mongo.NewSingleResultFromDocument(bson.D{}, err, nil)
 }
 
 type Collection struct {
-	coll      *mongo.Collection
+	database  *Database
+	name      string
+	opts      []*options.CollectionOptions
+	coll      atomic.Pointer[mongo.Collection]
 	processor processor
 	logMode   bool
 }
 
-func (wc *Collection) cmd(c *cmd) *cmd {
-	c.dbName = wc.coll.Database().Name()
-	c.collName = wc.coll.Name()
+// collection 返回当前driver client对应的mongo.Collection，driver client被替换后重新创建，OnFail=lazy还没有连接成功时返回nil
+func (wc *Collection) collection() *mongo.Collection {
+	db := wc.database.database()
+	if db == nil {
+		return nil
+	}
+	coll := wc.coll.Load()
+	if coll == nil || coll.Database() != db {
+		coll = db.Collection(wc.name, wc.opts...)
+		wc.coll.Store(coll)
+	}
+	return coll
+}
+
+// contextCollection 返回使用ctx中读偏好、读写关注的mongo.Collection，ctx中有session时使用session所属的driver client
+func (wc *Collection) contextCollection(ctx context.Context) *mongo.Collection {
+	coll := wc.collection()
+	if db := wc.database.contextDatabase(ctx); db != nil && coll != nil && db != coll.Database() {
+		coll = db.Collection(wc.name, wc.opts...)
+	}
+	return applyCallOptions(ctx, coll)
+}
+
+func (wc *Collection) newCmd(ctx context.Context, name string, filter, update, opts interface{}) *Cmd {
+	c := newCmd(ctx, name)
+	c.DbName = wc.database.name
+	c.CollName = wc.name
+	c.Filter = filter
+	c.Update = update
+	c.Opts = opts
 	return c
 }
 
-func (wc *Collection) Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (res *mongo.Cursor, err error) {
-	err = wc.processor(func(c *cmd) error {
-		res, err = wc.coll.Aggregate(ctx, pipeline, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "Aggregate", res, pipeline)
+func (wc *Collection) Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (res *Cursor, err error) {
+	var cur *mongo.Cursor
+	var release func()
+	cmd := wc.newCmd(ctx, "Aggregate", pipeline, nil, opts)
+	err = wc.processor(cmd, func(c *Cmd) error {
+		coll, done := wc.contextCollection(c.Ctx), func() {}
+		// $out、$merge会写入数据，只能发送到主连接
+		if !hasWriteStage(pipeline) {
+			coll, done = wc.readCollection(c.Ctx)
+		}
+		cur, err = coll.Aggregate(c.Ctx, pipeline, maxTimeOptions(c.Ctx, opts, options.Aggregate().SetMaxTime)...)
+		release = cursorRelease(cur, done)
+		logCmd(wc.logMode, c, cur, pipeline)
 		return err
 	})
-	return
+	return newCursor(cur, wc.processor, wc.logMode, cmd, release), err
 }
 
 func (wc *Collection) BulkWrite(ctx context.Context, models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (
 	res *mongo.BulkWriteResult, err error) {
 
-	err = wc.processor(func(c *cmd) error {
-		res, err = wc.coll.BulkWrite(ctx, models, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "BulkWrite", res, models)
+	err = wc.processor(wc.newCmd(ctx, "BulkWrite", nil, models, opts), func(c *Cmd) error {
+		res, err = wc.contextCollection(c.Ctx).BulkWrite(c.Ctx, models, opts...)
+		logCmd(wc.logMode, c, res, models)
 		return err
 	})
 	return
 }
 
 func (wc *Collection) Clone(opts ...*options.CollectionOptions) (res *mongo.Collection, err error) {
-	err = wc.processor(func(c *cmd) error {
-		res, err = wc.coll.Clone(opts...)
-		logCmd(wc.logMode, wc.cmd(c), "Clone", res)
+	err = wc.processor(wc.newCmd(context.Background(), "Clone", nil, nil, opts), func(c *Cmd) error {
+		res, err = wc.collection().Clone(opts...)
+		logCmd(wc.logMode, c, res)
 		return err
 	})
 	return
 }
 
 func (wc *Collection) CountDocuments(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (res int64, err error) {
-	err = wc.processor(func(c *cmd) error {
-		res, err = wc.coll.CountDocuments(ctx, filter, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "CountDocuments", res, filter)
+	err = wc.processor(wc.newCmd(ctx, "CountDocuments", filter, nil, opts), func(c *Cmd) error {
+		coll, done := wc.readCollection(c.Ctx)
+		defer done()
+		res, err = coll.CountDocuments(c.Ctx, filter, maxTimeOptions(c.Ctx, opts, options.Count().SetMaxTime)...)
+		logCmd(wc.logMode, c, res, filter)
 		return err
 	})
 	return res, err
 }
 
-func (wc *Collection) Database() *mongo.Database { return wc.coll.Database() }
+// Database 返回当前driver client对应的mongo.Database，不经过拦截器，OnFail=lazy还没有连接成功时返回nil
+func (wc *Collection) Database() *mongo.Database { return wc.database.database() }
 
 func (wc *Collection) DeleteMany(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (
 	res *mongo.DeleteResult, err error) {
 
-	err = wc.processor(func(c *cmd) error {
-		res, err = wc.coll.DeleteMany(ctx, filter, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "DeleteMany", res, filter)
+	err = wc.processor(wc.newCmd(ctx, "DeleteMany", filter, nil, opts), func(c *Cmd) error {
+		res, err = wc.contextCollection(c.Ctx).DeleteMany(c.Ctx, filter, opts...)
+		logCmd(wc.logMode, c, res, filter)
 		return err
 	})
 	return
 }
 
 func (wc *Collection) DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (res *mongo.DeleteResult, err error) {
-	err = wc.processor(func(c *cmd) error {
-		res, err = wc.coll.DeleteOne(ctx, filter, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "DeleteOne", res, filter)
+	err = wc.processor(wc.newCmd(ctx, "DeleteOne", filter, nil, opts), func(c *Cmd) error {
+		res, err = wc.contextCollection(c.Ctx).DeleteOne(c.Ctx, filter, opts...)
+		logCmd(wc.logMode, c, res, filter)
 		return err
 	})
 	return
 }
 
 func (wc *Collection) Distinct(ctx context.Context, fieldName string, filter interface{}, opts ...*options.DistinctOptions) (res []interface{}, err error) {
-	err = wc.processor(func(c *cmd) error {
-		res, err = wc.coll.Distinct(ctx, fieldName, filter, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "Distinct", nil, fieldName, filter)
+	err = wc.processor(wc.newCmd(ctx, "Distinct", filter, nil, opts), func(c *Cmd) error {
+		coll, done := wc.readCollection(c.Ctx)
+		defer done()
+		res, err = coll.Distinct(c.Ctx, fieldName, filter, maxTimeOptions(c.Ctx, opts, options.Distinct().SetMaxTime)...)
+		logCmd(wc.logMode, c, nil, fieldName, filter)
 		return err
 	})
 	return
 }
 
 func (wc *Collection) Drop(ctx context.Context) error {
-	return wc.processor(func(c *cmd) error {
-		logCmd(wc.logMode, wc.cmd(c), "Drop", nil)
-		return wc.coll.Drop(ctx)
+	return wc.processor(wc.newCmd(ctx, "Drop", nil, nil, nil), func(c *Cmd) error {
+		logCmd(wc.logMode, c, nil)
+		return wc.contextCollection(c.Ctx).Drop(c.Ctx)
 	})
 }
 
 func (wc *Collection) EstimatedDocumentCount(ctx context.Context, opts ...*options.EstimatedDocumentCountOptions) (res int64, err error) {
-	err = wc.processor(func(c *cmd) error {
-		res, err = wc.coll.EstimatedDocumentCount(ctx, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "EstimatedDocumentCount", res)
+	err = wc.processor(wc.newCmd(ctx, "EstimatedDocumentCount", nil, nil, opts), func(c *Cmd) error {
+		res, err = wc.contextCollection(c.Ctx).EstimatedDocumentCount(c.Ctx, maxTimeOptions(c.Ctx, opts, options.EstimatedDocumentCount().SetMaxTime)...)
+		logCmd(wc.logMode, c, res)
 		return err
 	})
 	return
 }
 
-func (wc *Collection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (res *mongo.Cursor, err error) {
-	err = wc.processor(func(c *cmd) error {
-		res, err = wc.coll.Find(ctx, filter, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "Find", res, filter)
+func (wc *Collection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (res *Cursor, err error) {
+	var cur *mongo.Cursor
+	var release func()
+	cmd := wc.newCmd(ctx, "Find", filter, nil, opts)
+	err = wc.processor(cmd, func(c *Cmd) error {
+		coll, done := wc.readCollection(c.Ctx)
+		cur, err = coll.Find(c.Ctx, filter, maxTimeOptions(c.Ctx, opts, options.Find().SetMaxTime)...)
+		release = cursorRelease(cur, done)
+		logCmd(wc.logMode, c, cur, filter)
 		return err
 	})
-	return
+	return newCursor(cur, wc.processor, wc.logMode, cmd, release), err
 }
 
 func (wc *Collection) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) (res *mongo.SingleResult) {
-	_ = wc.processor(func(c *cmd) error {
-		res = wc.coll.FindOne(ctx, filter, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "FindOne", res, filter)
+	err := wc.processor(wc.newCmd(ctx, "FindOne", filter, nil, opts), func(c *Cmd) error {
+		coll, done := wc.readCollection(c.Ctx)
+		defer done()
+		res = coll.FindOne(c.Ctx, filter, maxTimeOptions(c.Ctx, opts, options.FindOne().SetMaxTime)...)
+		logCmd(wc.logMode, c, res, filter)
 		return res.Err()
 	})
-	return
+	return singleResult(res, err)
 }
 
 func (wc *Collection) FindOneAndDelete(ctx context.Context, filter interface{}, opts ...*options.FindOneAndDeleteOptions) (res *mongo.SingleResult) {
-	_ = wc.processor(func(c *cmd) error {
-		res = wc.coll.FindOneAndDelete(ctx, filter, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "FindOneAndDelete", res, filter)
+	err := wc.processor(wc.newCmd(ctx, "FindOneAndDelete", filter, nil, opts), func(c *Cmd) error {
+		res = wc.contextCollection(c.Ctx).FindOneAndDelete(c.Ctx, filter, maxTimeOptions(c.Ctx, opts, options.FindOneAndDelete().SetMaxTime)...)
+		logCmd(wc.logMode, c, res, filter)
 		return res.Err()
 	})
-	return
+	return singleResult(res, err)
 }
 
 func (wc *Collection) FindOneAndReplace(ctx context.Context, filter, replacement interface{}, opts ...*options.FindOneAndReplaceOptions) (res *mongo.SingleResult) {
-	_ = wc.processor(func(c *cmd) error {
-		res = wc.coll.FindOneAndReplace(ctx, filter, replacement, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "FindOneAndReplace", res, filter)
+	err := wc.processor(wc.newCmd(ctx, "FindOneAndReplace", filter, replacement, opts), func(c *Cmd) error {
+		res = wc.contextCollection(c.Ctx).FindOneAndReplace(c.Ctx, filter, replacement, maxTimeOptions(c.Ctx, opts, options.FindOneAndReplace().SetMaxTime)...)
+		logCmd(wc.logMode, c, res, filter)
 		return res.Err()
 	})
-	return
+	return singleResult(res, err)
 }
 
 func (wc *Collection) FindOneAndUpdate(ctx context.Context, filter, update interface{}, opts ...*options.FindOneAndUpdateOptions) (res *mongo.SingleResult) {
-	_ = wc.processor(func(c *cmd) error {
-		res = wc.coll.FindOneAndUpdate(ctx, filter, update, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "FindOneAndReplace", res, filter)
+	err := wc.processor(wc.newCmd(ctx, "FindOneAndUpdate", filter, update, opts), func(c *Cmd) error {
+		res = wc.contextCollection(c.Ctx).FindOneAndUpdate(c.Ctx, filter, update, maxTimeOptions(c.Ctx, opts, options.FindOneAndUpdate().SetMaxTime)...)
+		logCmd(wc.logMode, c, res, filter)
 		return res.Err()
 	})
-	return
+	return singleResult(res, err)
 }
 
-func (wc *Collection) Indexes() mongo.IndexView { return wc.coll.Indexes() }
+// Indexes 返回集合的索引操作，经过拦截器，OnFail=lazy还没有连接成功时返回ErrNotConnected
+func (wc *Collection) Indexes() *IndexView {
+	return &IndexView{coll: wc}
+}
 
 func (wc *Collection) InsertMany(ctx context.Context, documents []interface{}, opts ...*options.InsertManyOptions) (res *mongo.InsertManyResult, err error) {
-	_ = wc.processor(func(c *cmd) error {
-		res, err = wc.coll.InsertMany(ctx, documents, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "FindOneAndReplace", res, documents)
+	err = wc.processor(wc.newCmd(ctx, "InsertMany", nil, documents, opts), func(c *Cmd) error {
+		res, err = wc.contextCollection(c.Ctx).InsertMany(c.Ctx, documents, opts...)
+		logCmd(wc.logMode, c, res, documents)
 		return err
 	})
 	return
 }
 
 func (wc *Collection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (res *mongo.InsertOneResult, err error) {
-	_ = wc.processor(func(c *cmd) error {
-		res, err = wc.coll.InsertOne(ctx, document, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "InsertOne", res, document)
+	err = wc.processor(wc.newCmd(ctx, "InsertOne", nil, document, opts), func(c *Cmd) error {
+		res, err = wc.contextCollection(c.Ctx).InsertOne(c.Ctx, document, opts...)
+		logCmd(wc.logMode, c, res, document)
 		return err
 	})
 	return
 }
 
 func (wc *Collection) UpdateByID(ctx context.Context, id interface{}, update interface{}, opts ...*options.UpdateOptions) (res *mongo.UpdateResult, err error) {
-	_ = wc.processor(func(c *cmd) error {
-		res, err = wc.coll.UpdateByID(ctx, id, update, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "UpdateByID", res, id, update)
+	err = wc.processor(wc.newCmd(ctx, "UpdateByID", id, update, opts), func(c *Cmd) error {
+		res, err = wc.contextCollection(c.Ctx).UpdateByID(c.Ctx, id, update, opts...)
+		logCmd(wc.logMode, c, res, id, update)
 		return err
 	})
 	return
 }
 
-func (wc *Collection) Name() string { return wc.coll.Name() }
+func (wc *Collection) Name() string { return wc.name }
 
 func (wc *Collection) ReplaceOne(ctx context.Context, filter, replacement interface{}, opts ...*options.ReplaceOptions) (res *mongo.UpdateResult, err error) {
-	_ = wc.processor(func(c *cmd) error {
-		res, err = wc.coll.ReplaceOne(ctx, filter, replacement, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "ReplaceOne", res, filter, replacement)
+	err = wc.processor(wc.newCmd(ctx, "ReplaceOne", filter, replacement, opts), func(c *Cmd) error {
+		res, err = wc.contextCollection(c.Ctx).ReplaceOne(c.Ctx, filter, replacement, opts...)
+		logCmd(wc.logMode, c, res, filter, replacement)
 		return err
 	})
 	return
 }
 
 func (wc *Collection) UpdateMany(ctx context.Context, filter, replacement interface{}, opts ...*options.UpdateOptions) (res *mongo.UpdateResult, err error) {
-	_ = wc.processor(func(c *cmd) error {
-		res, err = wc.coll.UpdateMany(ctx, filter, replacement, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "UpdateMany", res, filter, replacement)
+	err = wc.processor(wc.newCmd(ctx, "UpdateMany", filter, replacement, opts), func(c *Cmd) error {
+		res, err = wc.contextCollection(c.Ctx).UpdateMany(c.Ctx, filter, replacement, opts...)
+		logCmd(wc.logMode, c, res, filter, replacement)
 		return err
 	})
 	return
 }
 
 func (wc *Collection) UpdateOne(ctx context.Context, filter, replacement interface{}, opts ...*options.UpdateOptions) (res *mongo.UpdateResult, err error) {
-	_ = wc.processor(func(c *cmd) error {
-		res, err = wc.coll.UpdateOne(ctx, filter, replacement, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "UpdateOne", res, filter, replacement)
+	err = wc.processor(wc.newCmd(ctx, "UpdateOne", filter, replacement, opts), func(c *Cmd) error {
+		res, err = wc.contextCollection(c.Ctx).UpdateOne(c.Ctx, filter, replacement, opts...)
+		logCmd(wc.logMode, c, res, filter, replacement)
 		return err
 	})
 	return
 }
 
-func (wc *Collection) Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (res *mongo.ChangeStream, err error) {
-	_ = wc.processor(func(c *cmd) error {
-		res, err = wc.coll.Watch(ctx, pipeline, opts...)
-		logCmd(wc.logMode, wc.cmd(c), "Watch", res, pipeline)
-		return err
+func (wc *Collection) Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (*ChangeStream, error) {
+	return wc.WatchWithStore(ctx, nil, "", pipeline, opts...)
+}
+
+// WatchWithStore 创建change stream，启动时从store中读取key对应的resume token继续消费，并在消费过程中保存resume token
+func (wc *Collection) WatchWithStore(ctx context.Context, store ResumeTokenStore, key string, pipeline interface{},
+	opts ...*options.ChangeStreamOptions) (*ChangeStream, error) {
+
+	cmd := wc.newCmd(ctx, "Watch", pipeline, nil, opts)
+	return watch(wc.processor, wc.logMode, cmd, store, key, opts, func(ctx context.Context, opts *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+		return wc.contextCollection(ctx).Watch(ctx, pipeline, opts)
 	})
-	return
 }
 
+// Collection 返回当前driver client对应的mongo.Collection，不经过拦截器，OnFail=lazy还没有连接成功时返回nil
 func (wc *Collection) Collection() *mongo.Collection {
-	return wc.coll
+	return wc.collection()
 }
@@ -0,0 +1,170 @@
+package emongo
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// cursorCloseTimeout 游标没有Close就被回收时，关闭server端游标的超时时间
+const cursorCloseTimeout = 5 * time.Second
+
+// Cursor 对mongo.Cursor的封装
+// 需要发送getMore的Next、TryNext，以及All、Close会经过拦截器，关闭时会记录返回的文档数量和游标的总耗时
+type Cursor struct {
+	*mongo.Cursor
+	processor processor
+	logMode   bool
+	origin    *Cmd
+	beg       time.Time
+	end       time.Time
+	returned  int64
+	closed    int32
+	// release 游标读完或者关闭后调用，只读连接在此之前不会因为替换而断开
+	release  func()
+	released int32
+}
+
+// newCursor release不为nil时，cur为nil或者游标已经读完时立即调用
+func newCursor(cur *mongo.Cursor, processor processor, logMode bool, origin *Cmd, release func()) *Cursor {
+	if cur == nil {
+		if release != nil {
+			release()
+		}
+		return nil
+	}
+	wc := &Cursor{
+		Cursor:    cur,
+		processor: processor,
+		logMode:   logMode,
+		origin:    origin,
+		beg:       time.Now(),
+		release:   release,
+	}
+	if cur.ID() == 0 {
+		wc.releaseConn()
+	}
+	// 游标没有Close就被回收，server端的游标会一直占用资源，直到超时
+	runtime.SetFinalizer(wc, (*Cursor).reportNotClosed)
+	return wc
+}
+
+// releaseConn 释放游标占用的连接，只执行一次
+func (wc *Cursor) releaseConn() {
+	if wc.release != nil && atomic.CompareAndSwapInt32(&wc.released, 0, 1) {
+		wc.release()
+	}
+}
+
+func (wc *Cursor) newCmd(ctx context.Context, name string) *Cmd {
+	c := newCmd(ctx, name)
+	c.DbName = wc.origin.DbName
+	c.CollName = wc.origin.CollName
+	c.Filter = wc.origin.Filter
+	return c
+}
+
+// Next 获取下一个文档，当前batch已经读完时会发送getMore
+func (wc *Cursor) Next(ctx context.Context) bool {
+	return wc.next(ctx, wc.Cursor.Next)
+}
+
+// TryNext 尝试获取下一个文档，当前batch已经读完时会发送一次getMore
+func (wc *Cursor) TryNext(ctx context.Context) bool {
+	return wc.next(ctx, wc.Cursor.TryNext)
+}
+
+func (wc *Cursor) next(ctx context.Context, fn func(context.Context) bool) (ok bool) {
+	// 当前batch还有数据，或者server端游标已经结束，都不会发送getMore
+	if wc.Cursor.RemainingBatchLength() > 0 || wc.Cursor.ID() == 0 {
+		ok = fn(ctx)
+	} else {
+		_ = wc.processor(wc.newCmd(ctx, "getMore"), func(c *Cmd) error {
+			ok = fn(c.Ctx)
+			logCmd(wc.logMode, c, nil)
+			return wc.Cursor.Err()
+		})
+	}
+	if ok {
+		wc.returned++
+	} else if wc.Cursor.ID() == 0 {
+		if wc.end.IsZero() {
+			wc.end = time.Now()
+		}
+		wc.releaseConn()
+	}
+	return ok
+}
+
+// All 读取剩余的所有文档到results中，并关闭游标
+func (wc *Cursor) All(ctx context.Context, results interface{}) error {
+	return wc.processor(wc.newCmd(ctx, "All"), func(c *Cmd) error {
+		err := wc.Cursor.All(c.Ctx, results)
+		wc.releaseConn()
+		wc.returned += resultsLen(results)
+		wc.finish(c)
+		logCmd(wc.logMode, c, results)
+		return err
+	})
+}
+
+// Close 关闭游标，已经Close或者All之后不再经过拦截器，直接返回nil
+func (wc *Cursor) Close(ctx context.Context) error {
+	if atomic.LoadInt32(&wc.closed) == 1 {
+		return nil
+	}
+	return wc.processor(wc.newCmd(ctx, "Close"), func(c *Cmd) error {
+		err := wc.Cursor.Close(c.Ctx)
+		wc.releaseConn()
+		wc.finish(c)
+		logCmd(wc.logMode, c, nil)
+		return err
+	})
+}
+
+func (wc *Cursor) finish(c *Cmd) {
+	if !atomic.CompareAndSwapInt32(&wc.closed, 0, 1) {
+		return
+	}
+	runtime.SetFinalizer(wc, nil)
+	end := wc.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+	c.ReturnedCount = wc.returned
+	c.IterationCost = end.Sub(wc.beg)
+}
+
+// reportNotClosed 在finalizer中执行，所有的finalizer共用一个goroutine，这里只经过拦截器记录日志和监控，不访问服务端
+// 关闭server端游标需要发送killCursors，放到单独的goroutine中，并设置超时时间
+func (wc *Cursor) reportNotClosed() {
+	if atomic.LoadInt32(&wc.closed) == 1 || wc.Cursor.ID() == 0 {
+		wc.releaseConn()
+		return
+	}
+	_ = wc.processor(wc.newCmd(context.Background(), "CursorNotClosed"), func(c *Cmd) error {
+		wc.finish(c)
+		return ErrCursorNotClosed
+	})
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cursorCloseTimeout)
+		defer cancel()
+		_ = wc.Cursor.Close(ctx)
+		wc.releaseConn()
+	}()
+}
+
+func resultsLen(results interface{}) int64 {
+	val := reflect.ValueOf(results)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Slice {
+		return 0
+	}
+	return int64(val.Len())
+}
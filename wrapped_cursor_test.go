@@ -0,0 +1,116 @@
+package emongo
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func newTestCursor(t *testing.T, cmds *[]*Cmd) *Cursor {
+	cur, err := mongo.NewCursorFromDocuments([]interface{}{bson.M{"a": 1}, bson.M{"a": 2}, bson.M{"a": 3}}, nil, nil)
+	assert.NoError(t, err)
+	processor := func(c *Cmd, fn ProcessFn) error {
+		*cmds = append(*cmds, c)
+		return fn(c)
+	}
+	return newCursor(cur, processor, false, &Cmd{DbName: "test", CollName: "cells"}, nil)
+}
+
+func TestCursor_Close(t *testing.T) {
+	var cmds []*Cmd
+	ctx := context.Background()
+	cur := newTestCursor(t, &cmds)
+	for cur.Next(ctx) {
+		var result bson.M
+		assert.NoError(t, cur.Decode(&result))
+	}
+	assert.NoError(t, cur.Close(ctx))
+
+	assert.Len(t, cmds, 1)
+	assert.Equal(t, "Close", cmds[0].Name)
+	assert.Equal(t, "cells", cmds[0].CollName)
+	assert.Equal(t, int64(3), cmds[0].ReturnedCount)
+	assert.True(t, cmds[0].IterationCost > 0)
+
+	// 重复Close不会重复记录
+	assert.NoError(t, cur.Close(ctx))
+	assert.Len(t, cmds, 1)
+}
+
+func TestCursor_All(t *testing.T) {
+	var cmds []*Cmd
+	cur := newTestCursor(t, &cmds)
+	var results []bson.M
+	assert.NoError(t, cur.All(context.Background(), &results))
+
+	assert.Len(t, results, 3)
+	assert.Len(t, cmds, 1)
+	assert.Equal(t, "All", cmds[0].Name)
+	assert.Equal(t, int64(3), cmds[0].ReturnedCount)
+}
+
+func TestCursor_GetMore(t *testing.T) {
+	client, md := newMockClient(t, cursorResponse("test.cells", 42, "firstBatch", bson.M{"a": 1}))
+	var names []string
+	client.wrapProcessor(InterceptorChain(func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
+			names = append(names, cmd.Name)
+			return oldProcess(cmd)
+		}
+	}))
+	ctx := context.Background()
+	cur, err := client.Database("test").Collection("cells").Find(ctx, bson.M{})
+	assert.NoError(t, err)
+
+	// 第一个batch读完后才发送getMore，server端游标结束后不再发送
+	md.addResponses(cursorResponse("test.cells", 0, "nextBatch", bson.M{"a": 2}))
+	count := 0
+	for cur.Next(ctx) {
+		count++
+	}
+	assert.NoError(t, cur.Err())
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []string{"Database", "Find", "getMore"}, names)
+	md.waitSent(t, "getMore")
+}
+
+func TestCursor_NotClosed(t *testing.T) {
+	client, md := newMockClient(t, cursorResponse("test.cells", 42, "firstBatch", bson.M{"a": 1}))
+	cmds := make(chan *Cmd, 1)
+	client.wrapProcessor(InterceptorChain(func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
+			err := oldProcess(cmd)
+			if cmd.Name == "CursorNotClosed" {
+				assert.Equal(t, ErrCursorNotClosed, err)
+				cmds <- cmd
+			}
+			return err
+		}
+	}))
+	func() {
+		cur, err := client.Database("test").Collection("cells").Find(context.Background(), bson.M{})
+		assert.NoError(t, err)
+		assert.True(t, cur.Next(context.Background()))
+	}()
+	md.addResponses(bson.D{{Key: "ok", Value: 1}})
+
+	// 游标没有Close就被回收时，记录日志和监控，并在后台发送killCursors
+	var cmd *Cmd
+	for i := 0; i < 50 && cmd == nil; i++ {
+		runtime.GC()
+		select {
+		case cmd = <-cmds:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if assert.NotNil(t, cmd) {
+		assert.Equal(t, "cells", cmd.CollName)
+		assert.Equal(t, int64(1), cmd.ReturnedCount)
+	}
+	md.waitSent(t, "killCursors")
+}
@@ -16,7 +16,7 @@ package emongo
 
 import (
 	"context"
-	"sync"
+	"sync/atomic"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -26,73 +26,120 @@ import (
 )
 
 type Database struct {
-	mu        sync.Mutex
-	db        *mongo.Database
+	client    *Client
+	name      string
+	opts      []*options.DatabaseOptions
+	db        atomic.Pointer[mongo.Database]
 	processor processor
 	logMode   bool
 }
 
-func (wd *Database) Client() *Client {
-	wd.mu.Lock()
-	defer wd.mu.Unlock()
-
-	cc := wd.db.Client()
+// database 返回当前driver client对应的mongo.Database，driver client被替换后重新创建，OnFail=lazy还没有连接成功时返回nil
+func (wd *Database) database() *mongo.Database {
+	cc := wd.client.Client()
 	if cc == nil {
 		return nil
 	}
-	return &Client{cc: cc}
+	db := wd.db.Load()
+	if db == nil || db.Client() != cc {
+		db = cc.Database(wd.name, wd.opts...)
+		wd.db.Store(db)
+	}
+	return db
 }
 
-func (wd *Database) Collection(name string, opts ...*options.CollectionOptions) *Collection {
-	if wd.db == nil {
-		return nil
-	}
-	coll := wd.db.Collection(name, opts...)
-	if coll == nil {
-		return nil
+// contextDatabase ctx中有session时使用session所属的driver client，driver client被替换后，进行中的session依然使用原来的client
+func (wd *Database) contextDatabase(ctx context.Context) *mongo.Database {
+	db := wd.database()
+	if sess := mongo.SessionFromContext(ctx); sess != nil && db != nil && sess.Client() != db.Client() {
+		return sess.Client().Database(wd.name, wd.opts...)
 	}
-	return &Collection{coll: coll, processor: wd.processor, logMode: wd.logMode}
+	return db
+}
+
+func (wd *Database) Client() *Client {
+	return wd.client
+}
+
+func (wd *Database) newCmd(ctx context.Context, name string, filter, opts interface{}) *Cmd {
+	c := newCmd(ctx, name)
+	c.DbName = wd.name
+	c.Filter = filter
+	c.Opts = opts
+	return c
+}
+
+func (wd *Database) Collection(name string, opts ...*options.CollectionOptions) *Collection {
+	return &Collection{database: wd, name: name, opts: opts, processor: wd.processor, logMode: wd.logMode}
 }
 
 func (wd *Database) Drop(ctx context.Context) error {
-	return wd.processor(func(c *cmd) error {
-		logCmd(wd.logMode, c, "Drop", nil)
-		return wd.db.Drop(ctx)
+	return wd.processor(wd.newCmd(ctx, "Drop", nil, nil), func(c *Cmd) error {
+		logCmd(wd.logMode, c, nil)
+		return wd.contextDatabase(c.Ctx).Drop(c.Ctx)
 	})
 }
 
 func (wd *Database) ListCollections(ctx context.Context, filter interface{}, opts ...*options.ListCollectionsOptions) (
-	cur *mongo.Cursor, err error) {
-	err = wd.processor(func(c *cmd) error {
-		cur, err = wd.db.ListCollections(ctx, filter, opts...)
-		logCmd(wd.logMode, c, "ListCollections", cur, filter)
+	res *Cursor, err error) {
+	var cur *mongo.Cursor
+	cmd := wd.newCmd(ctx, "ListCollections", filter, opts)
+	err = wd.processor(cmd, func(c *Cmd) error {
+		cur, err = wd.contextDatabase(c.Ctx).ListCollections(c.Ctx, filter, opts...)
+		logCmd(wd.logMode, c, cur, filter)
 		return err
 	})
-	return
+	return newCursor(cur, wd.processor, wd.logMode, cmd, nil), err
 }
 
-func (wd *Database) Name() string                          { return wd.db.Name() }
-func (wd *Database) ReadConcern() *readconcern.ReadConcern { return wd.db.ReadConcern() }
-func (wd *Database) ReadPreference() *readpref.ReadPref    { return wd.db.ReadPreference() }
+func (wd *Database) Name() string { return wd.name }
+func (wd *Database) ReadConcern() *readconcern.ReadConcern {
+	if db := wd.database(); db != nil {
+		return db.ReadConcern()
+	}
+	return nil
+}
+
+func (wd *Database) ReadPreference() *readpref.ReadPref {
+	if db := wd.database(); db != nil {
+		return db.ReadPreference()
+	}
+	return nil
+}
 
 func (wd *Database) RunCommand(ctx context.Context, runCommand interface{}, opts ...*options.RunCmdOptions) (res *mongo.SingleResult) {
-	_ = wd.processor(func(c *cmd) error {
-		res = wd.db.RunCommand(ctx, runCommand, opts...)
-		logCmd(wd.logMode, c, "RunCommand", res, runCommand)
+	err := wd.processor(wd.newCmd(ctx, "RunCommand", runCommand, opts), func(c *Cmd) error {
+		res = wd.contextDatabase(c.Ctx).RunCommand(c.Ctx, runCommand, opts...)
+		logCmd(wd.logMode, c, res, runCommand)
 		return res.Err()
 	})
-	return
+	return singleResult(res, err)
 }
 
 func (wd *Database) WriteConcern() (res *writeconcern.WriteConcern) {
-	_ = wd.processor(func(c *cmd) error {
-		res = wd.db.WriteConcern()
-		logCmd(wd.logMode, c, "WriteConcern", res)
+	_ = wd.processor(wd.newCmd(context.Background(), "WriteConcern", nil, nil), func(c *Cmd) error {
+		res = wd.database().WriteConcern()
+		logCmd(wd.logMode, c, res)
 		return nil
 	})
 	return
 }
 
+func (wd *Database) Watch(ctx context.Context, pipeline interface{}, opts ...*options.ChangeStreamOptions) (*ChangeStream, error) {
+	return wd.WatchWithStore(ctx, nil, "", pipeline, opts...)
+}
+
+// WatchWithStore 创建change stream，启动时从store中读取key对应的resume token继续消费，并在消费过程中保存resume token
+func (wd *Database) WatchWithStore(ctx context.Context, store ResumeTokenStore, key string, pipeline interface{},
+	opts ...*options.ChangeStreamOptions) (*ChangeStream, error) {
+
+	cmd := wd.newCmd(ctx, "Watch", pipeline, opts)
+	return watch(wd.processor, wd.logMode, cmd, store, key, opts, func(ctx context.Context, opts *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+		return wd.contextDatabase(ctx).Watch(ctx, pipeline, opts)
+	})
+}
+
+// Database 返回当前driver client对应的mongo.Database，不经过拦截器，OnFail=lazy还没有连接成功时返回nil
 func (wd *Database) Database() *mongo.Database {
-	return wd.db
+	return wd.database()
 }
@@ -0,0 +1,75 @@
+package emongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IndexView 集合的索引操作，经过拦截器，OnFail=lazy还没有连接成功时返回ErrNotConnected
+type IndexView struct {
+	coll *Collection
+}
+
+// indexView 返回当前driver client对应的mongo.IndexView，只在processor中调用，此时已经连接成功
+func (iv *IndexView) indexView(ctx context.Context) mongo.IndexView {
+	return iv.coll.contextCollection(ctx).Indexes()
+}
+
+func (iv *IndexView) List(ctx context.Context, opts ...*options.ListIndexesOptions) (res *Cursor, err error) {
+	var cur *mongo.Cursor
+	cmd := iv.coll.newCmd(ctx, "ListIndexes", nil, nil, opts)
+	err = iv.coll.processor(cmd, func(c *Cmd) error {
+		cur, err = iv.indexView(c.Ctx).List(c.Ctx, opts...)
+		logCmd(iv.coll.logMode, c, cur)
+		return err
+	})
+	return newCursor(cur, iv.coll.processor, iv.coll.logMode, cmd, nil), err
+}
+
+func (iv *IndexView) ListSpecifications(ctx context.Context, opts ...*options.ListIndexesOptions) (res []*mongo.IndexSpecification, err error) {
+	err = iv.coll.processor(iv.coll.newCmd(ctx, "ListIndexSpecifications", nil, nil, opts), func(c *Cmd) error {
+		res, err = iv.indexView(c.Ctx).ListSpecifications(c.Ctx, opts...)
+		logCmd(iv.coll.logMode, c, res)
+		return err
+	})
+	return
+}
+
+func (iv *IndexView) CreateOne(ctx context.Context, model mongo.IndexModel, opts ...*options.CreateIndexesOptions) (res string, err error) {
+	err = iv.coll.processor(iv.coll.newCmd(ctx, "CreateIndex", nil, model, opts), func(c *Cmd) error {
+		res, err = iv.indexView(c.Ctx).CreateOne(c.Ctx, model, opts...)
+		logCmd(iv.coll.logMode, c, res, model)
+		return err
+	})
+	return
+}
+
+func (iv *IndexView) CreateMany(ctx context.Context, models []mongo.IndexModel, opts ...*options.CreateIndexesOptions) (res []string, err error) {
+	err = iv.coll.processor(iv.coll.newCmd(ctx, "CreateIndexes", nil, models, opts), func(c *Cmd) error {
+		res, err = iv.indexView(c.Ctx).CreateMany(c.Ctx, models, opts...)
+		logCmd(iv.coll.logMode, c, res, models)
+		return err
+	})
+	return
+}
+
+func (iv *IndexView) DropOne(ctx context.Context, name string, opts ...*options.DropIndexesOptions) (res bson.Raw, err error) {
+	err = iv.coll.processor(iv.coll.newCmd(ctx, "DropIndex", name, nil, opts), func(c *Cmd) error {
+		res, err = iv.indexView(c.Ctx).DropOne(c.Ctx, name, opts...)
+		logCmd(iv.coll.logMode, c, res, name)
+		return err
+	})
+	return
+}
+
+func (iv *IndexView) DropAll(ctx context.Context, opts ...*options.DropIndexesOptions) (res bson.Raw, err error) {
+	err = iv.coll.processor(iv.coll.newCmd(ctx, "DropIndexes", nil, nil, opts), func(c *Cmd) error {
+		res, err = iv.indexView(c.Ctx).DropAll(c.Ctx, opts...)
+		logCmd(iv.coll.logMode, c, res)
+		return err
+	})
+	return
+}
@@ -0,0 +1,46 @@
+package emongo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestIndexView(t *testing.T) {
+	client, md := newMockClient(t,
+		bson.D{{Key: "ok", Value: 1}},
+		cursorResponse("test.cells", 0, "firstBatch", bson.M{"name": "a_1", "key": bson.M{"a": 1}, "v": 2}),
+		bson.D{{Key: "ok", Value: 1}},
+	)
+	var names []string
+	client.wrapProcessor(InterceptorChain(func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
+			names = append(names, cmd.Name)
+			return oldProcess(cmd)
+		}
+	}))
+	ctx := context.Background()
+	indexes := client.Database("test").Collection("cells").Indexes()
+
+	name, err := indexes.CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "a", Value: 1}}})
+	require.NoError(t, err)
+	assert.Equal(t, "a_1", name)
+	assert.Equal(t, "cells", md.waitSent(t, "createIndexes").Lookup("createIndexes").StringValue())
+
+	cur, err := indexes.List(ctx)
+	require.NoError(t, err)
+	var specs []bson.M
+	require.NoError(t, cur.All(ctx, &specs))
+	require.Len(t, specs, 1)
+	assert.Equal(t, "a_1", specs[0]["name"])
+
+	_, err = indexes.DropOne(ctx, "a_1")
+	require.NoError(t, err)
+	assert.Equal(t, "a_1", md.waitSent(t, "dropIndexes").Lookup("index").StringValue())
+
+	assert.Equal(t, []string{"Database", "CreateIndex", "ListIndexes", "All", "DropIndex"}, names)
+}
@@ -40,71 +40,71 @@ var _ mongo.Session = (*session)(nil)
 //	ID() bson.Raw
 
 func (ws *session) EndSession(ctx context.Context) {
-	_ = ws.processor(func(c *cmd) error {
-		ws.Session.EndSession(ctx)
-		logCmd(ws.logMode, c, "EndSession", nil)
+	_ = ws.processor(newCmd(ctx, "EndSession"), func(c *Cmd) error {
+		ws.Session.EndSession(c.Ctx)
+		logCmd(ws.logMode, c, nil)
 		return nil
 	})
 }
 
 func (ws *session) StartTransaction(topts ...*options.TransactionOptions) error {
-	return ws.processor(func(c *cmd) error {
-		logCmd(ws.logMode, c, "StartTransaction", nil)
+	return ws.processor(newCmd(context.Background(), "StartTransaction"), func(c *Cmd) error {
+		logCmd(ws.logMode, c, nil)
 		return ws.Session.StartTransaction(topts...)
 	})
 }
 
 func (ws *session) AbortTransaction(ctx context.Context) error {
-	return ws.processor(func(c *cmd) error {
-		logCmd(ws.logMode, c, "AbortTransaction", nil)
-		return ws.Session.AbortTransaction(ctx)
+	return ws.processor(newCmd(ctx, "AbortTransaction"), func(c *Cmd) error {
+		logCmd(ws.logMode, c, nil)
+		return ws.Session.AbortTransaction(c.Ctx)
 	})
 }
 
 func (ws *session) CommitTransaction(ctx context.Context) error {
-	return ws.processor(func(c *cmd) error {
-		logCmd(ws.logMode, c, "CommitTransaction", nil)
-		return ws.Session.CommitTransaction(ctx)
+	return ws.processor(newCmd(ctx, "CommitTransaction"), func(c *Cmd) error {
+		logCmd(ws.logMode, c, nil)
+		return ws.Session.CommitTransaction(c.Ctx)
 	})
 }
 
 func (ws *session) ClusterTime() (raw bson.Raw) {
-	_ = ws.processor(func(c *cmd) error {
+	_ = ws.processor(newCmd(context.Background(), "ClusterTime"), func(c *Cmd) error {
 		raw = ws.Session.ClusterTime()
-		logCmd(ws.logMode, c, "ClusterTime", raw)
+		logCmd(ws.logMode, c, raw)
 		return nil
 	})
 	return
 }
 
 func (ws *session) AdvanceClusterTime(br bson.Raw) error {
-	return ws.processor(func(c *cmd) error {
-		logCmd(ws.logMode, c, "AdvanceClusterTime", nil)
+	return ws.processor(newCmd(context.Background(), "AdvanceClusterTime"), func(c *Cmd) error {
+		logCmd(ws.logMode, c, nil)
 		return ws.Session.AdvanceClusterTime(br)
 	})
 }
 
 func (ws *session) OperationTime() (ts *primitive.Timestamp) {
-	_ = ws.processor(func(c *cmd) error {
+	_ = ws.processor(newCmd(context.Background(), "OperationTime"), func(c *Cmd) error {
 		ts = ws.Session.OperationTime()
-		logCmd(ws.logMode, c, "OperationTime", ts)
+		logCmd(ws.logMode, c, ts)
 		return nil
 	})
 	return
 }
 
 func (ws *session) AdvanceOperationTime(pt *primitive.Timestamp) error {
-	return ws.processor(func(c *cmd) error {
-		logCmd(ws.logMode, c, "AdvanceOperationTime", nil)
+	return ws.processor(newCmd(context.Background(), "AdvanceOperationTime"), func(c *Cmd) error {
+		logCmd(ws.logMode, c, nil)
 		return ws.Session.AdvanceOperationTime(pt)
 	})
 }
 
 func (ws *session) WithTransaction(ctx context.Context, fn func(sessCtx SessionContext) (interface{}, error),
 	opts ...*options.TransactionOptions) (out interface{}, err error) {
-	err = ws.processor(func(c *cmd) error {
-		logCmd(ws.logMode, c, "WithTransaction", nil)
-		out, err = ws.Session.WithTransaction(ctx, fn, opts...)
+	err = ws.processor(newCmd(ctx, "WithTransaction"), func(c *Cmd) error {
+		logCmd(ws.logMode, c, nil)
+		out, err = ws.Session.WithTransaction(c.Ctx, fn, opts...)
 		return err
 	})
 	return
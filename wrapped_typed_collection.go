@@ -0,0 +1,137 @@
+package emongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TypedCollection 基于Collection的泛型封装，直接读写T类型的文档，所有操作依然经过拦截器
+// 没有涉及文档类型的方法，例如UpdateOne、DeleteMany、CountDocuments，直接使用内嵌的Collection
+type TypedCollection[T any] struct {
+	*Collection
+}
+
+// NewTypedCollection 创建T类型的TypedCollection
+func NewTypedCollection[T any](coll *Collection) *TypedCollection[T] {
+	return &TypedCollection[T]{Collection: coll}
+}
+
+// FindOne 查询一个文档，不存在时返回mongo.ErrNoDocuments
+func (tc *TypedCollection[T]) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) (res T, err error) {
+	err = tc.Collection.FindOne(ctx, filter, opts...).Decode(&res)
+	return
+}
+
+// Find 查询所有匹配的文档
+func (tc *TypedCollection[T]) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]T, error) {
+	cur, err := tc.FindCursor(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return cur.All(ctx)
+}
+
+// FindCursor 查询匹配的文档，返回T类型的游标，适合结果集较大、需要逐条处理的场景
+func (tc *TypedCollection[T]) FindCursor(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*TypedCursor[T], error) {
+	cur, err := tc.Collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedCursor[T]{Cursor: cur}, nil
+}
+
+// Aggregate 执行聚合，结果解析为T类型
+func (tc *TypedCollection[T]) Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) ([]T, error) {
+	cur, err := tc.AggregateCursor(ctx, pipeline, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return cur.All(ctx)
+}
+
+// AggregateCursor 执行聚合，返回T类型的游标
+func (tc *TypedCollection[T]) AggregateCursor(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (*TypedCursor[T], error) {
+	cur, err := tc.Collection.Aggregate(ctx, pipeline, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedCursor[T]{Cursor: cur}, nil
+}
+
+// FindOneAndDelete 删除一个文档，并返回删除前的文档
+func (tc *TypedCollection[T]) FindOneAndDelete(ctx context.Context, filter interface{}, opts ...*options.FindOneAndDeleteOptions) (res T, err error) {
+	err = tc.Collection.FindOneAndDelete(ctx, filter, opts...).Decode(&res)
+	return
+}
+
+// FindOneAndReplace 替换一个文档，默认返回替换前的文档
+func (tc *TypedCollection[T]) FindOneAndReplace(ctx context.Context, filter interface{}, replacement T, opts ...*options.FindOneAndReplaceOptions) (res T, err error) {
+	err = tc.Collection.FindOneAndReplace(ctx, filter, replacement, opts...).Decode(&res)
+	return
+}
+
+// FindOneAndUpdate 更新一个文档，默认返回更新前的文档
+func (tc *TypedCollection[T]) FindOneAndUpdate(ctx context.Context, filter, update interface{}, opts ...*options.FindOneAndUpdateOptions) (res T, err error) {
+	err = tc.Collection.FindOneAndUpdate(ctx, filter, update, opts...).Decode(&res)
+	return
+}
+
+// InsertOne 写入一个文档
+func (tc *TypedCollection[T]) InsertOne(ctx context.Context, document T, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	return tc.Collection.InsertOne(ctx, document, opts...)
+}
+
+// InsertMany 写入多个文档
+func (tc *TypedCollection[T]) InsertMany(ctx context.Context, documents []T, opts ...*options.InsertManyOptions) (*mongo.InsertManyResult, error) {
+	docs := make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		docs = append(docs, document)
+	}
+	return tc.Collection.InsertMany(ctx, docs, opts...)
+}
+
+// ReplaceOne 替换一个文档
+func (tc *TypedCollection[T]) ReplaceOne(ctx context.Context, filter interface{}, replacement T, opts ...*options.ReplaceOptions) (*mongo.UpdateResult, error) {
+	return tc.Collection.ReplaceOne(ctx, filter, replacement, opts...)
+}
+
+// TypedCursor T类型的游标
+type TypedCursor[T any] struct {
+	*Cursor
+}
+
+// Decode 解析当前文档
+func (tc *TypedCursor[T]) Decode() (res T, err error) {
+	err = tc.Cursor.Decode(&res)
+	return
+}
+
+// All 读取剩余的所有文档，并关闭游标
+func (tc *TypedCursor[T]) All(ctx context.Context) ([]T, error) {
+	res := make([]T, 0)
+	if err := tc.Cursor.All(ctx, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Each 逐条遍历剩余的文档，fn返回错误时停止遍历，结束后关闭游标
+func (tc *TypedCursor[T]) Each(ctx context.Context, fn func(T) error) (err error) {
+	defer func() {
+		if closeErr := tc.Cursor.Close(ctx); err == nil {
+			err = closeErr
+		}
+	}()
+	for tc.Cursor.Next(ctx) {
+		res, err := tc.Decode()
+		if err != nil {
+			return err
+		}
+		if err = fn(res); err != nil {
+			return err
+		}
+	}
+	return tc.Cursor.Err()
+}
@@ -0,0 +1,144 @@
+package emongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type cell struct {
+	A int `bson:"a"`
+}
+
+func TestTypedCursor_All(t *testing.T) {
+	var cmds []*Cmd
+	cur := &TypedCursor[cell]{Cursor: newTestCursor(t, &cmds)}
+	res, err := cur.All(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []cell{{A: 1}, {A: 2}, {A: 3}}, res)
+}
+
+func TestTypedCursor_Each(t *testing.T) {
+	var cmds []*Cmd
+	cur := &TypedCursor[cell]{Cursor: newTestCursor(t, &cmds)}
+	errStop := errors.New("stop")
+	var res []cell
+	err := cur.Each(context.Background(), func(c cell) error {
+		res = append(res, c)
+		if c.A == 2 {
+			return errStop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, []cell{{A: 1}, {A: 2}}, res)
+	// 提前结束也会关闭游标
+	assert.Equal(t, "Close", cmds[len(cmds)-1].Name)
+	assert.Equal(t, int64(2), cmds[len(cmds)-1].ReturnedCount)
+}
+
+// newTypedCollection 使用mockDeployment创建TypedCollection，返回经过拦截器的请求名称
+func newTypedCollection(t *testing.T, responses ...bson.D) (*TypedCollection[cell], *mockDeployment, *[]string) {
+	client, md := newMockClient(t, responses...)
+	names := &[]string{}
+	client.wrapProcessor(InterceptorChain(func(oldProcess ProcessFn) ProcessFn {
+		return func(cmd *Cmd) error {
+			if cmd.Name != "Database" {
+				*names = append(*names, cmd.Name)
+			}
+			return oldProcess(cmd)
+		}
+	}))
+	return NewTypedCollection[cell](client.Database("test").Collection("cells")), md, names
+}
+
+// findAndModifyResponse findAndModify的响应，value为nil时表示没有匹配的文档
+func findAndModifyResponse(value interface{}) bson.D {
+	return bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: value}}
+}
+
+func TestTypedCollection_Find(t *testing.T) {
+	tc, md, names := newTypedCollection(t,
+		cursorResponse("test.cells", 0, "firstBatch", bson.M{"a": 1}),
+		cursorResponse("test.cells", 0, "firstBatch", bson.M{"a": 1}, bson.M{"a": 2}),
+		cursorResponse("test.cells", 0, "firstBatch"),
+	)
+	ctx := context.Background()
+
+	res, err := tc.FindOne(ctx, bson.M{"a": 1})
+	require.NoError(t, err)
+	assert.Equal(t, cell{A: 1}, res)
+	list, err := tc.Find(ctx, bson.M{})
+	require.NoError(t, err)
+	assert.Equal(t, []cell{{A: 1}, {A: 2}}, list)
+
+	// 不存在时返回ErrNoDocuments和零值
+	res, err = tc.FindOne(ctx, bson.M{"a": 3})
+	assert.ErrorIs(t, err, mongo.ErrNoDocuments)
+	assert.Equal(t, cell{}, res)
+
+	assert.Equal(t, []string{"FindOne", "Find", "All", "FindOne"}, *names)
+	assert.Equal(t, "cells", md.sent()[0].Lookup("find").StringValue())
+}
+
+func TestTypedCollection_Insert(t *testing.T) {
+	tc, md, names := newTypedCollection(t,
+		bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}},
+		bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 2}},
+	)
+	ctx := context.Background()
+
+	_, err := tc.InsertOne(ctx, cell{A: 1})
+	require.NoError(t, err)
+	res, err := tc.InsertMany(ctx, []cell{{A: 2}, {A: 3}})
+	require.NoError(t, err)
+	assert.Len(t, res.InsertedIDs, 2)
+
+	assert.Equal(t, []string{"InsertOne", "InsertMany"}, *names)
+	sent := md.sent()
+	require.Len(t, sent, 2)
+	var docs []cell
+	for _, cmd := range sent {
+		values, err := cmd.Lookup("documents").Array().Values()
+		require.NoError(t, err)
+		for _, v := range values {
+			var doc cell
+			require.NoError(t, v.Unmarshal(&doc))
+			docs = append(docs, doc)
+		}
+	}
+	assert.Equal(t, []cell{{A: 1}, {A: 2}, {A: 3}}, docs)
+}
+
+func TestTypedCollection_FindOneAndModify(t *testing.T) {
+	tc, md, names := newTypedCollection(t,
+		findAndModifyResponse(bson.M{"a": 1}),
+		findAndModifyResponse(bson.M{"a": 2}),
+		findAndModifyResponse(bson.M{"a": 3}),
+		findAndModifyResponse(nil),
+	)
+	ctx := context.Background()
+
+	res, err := tc.FindOneAndDelete(ctx, bson.M{"a": 1})
+	require.NoError(t, err)
+	assert.Equal(t, cell{A: 1}, res)
+	res, err = tc.FindOneAndReplace(ctx, bson.M{"a": 2}, cell{A: 20})
+	require.NoError(t, err)
+	assert.Equal(t, cell{A: 2}, res)
+	res, err = tc.FindOneAndUpdate(ctx, bson.M{"a": 3}, bson.M{"$inc": bson.M{"a": 1}})
+	require.NoError(t, err)
+	assert.Equal(t, cell{A: 3}, res)
+	res, err = tc.FindOneAndDelete(ctx, bson.M{"a": 4})
+	assert.ErrorIs(t, err, mongo.ErrNoDocuments)
+	assert.Equal(t, cell{}, res)
+
+	assert.Equal(t, []string{"FindOneAndDelete", "FindOneAndReplace", "FindOneAndUpdate", "FindOneAndDelete"}, *names)
+	var replacement cell
+	require.NoError(t, md.sent()[1].Lookup("update").Unmarshal(&replacement))
+	assert.Equal(t, cell{A: 20}, replacement)
+}